@@ -0,0 +1,116 @@
+package multiconfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+var (
+	// ErrOverflow is reported when a value does not fit into the requested type
+	ErrOverflow = errors.New("value out of range")
+	// ErrLossy is reported when a value would change by the conversion, etc:
+	// 45.6 read as a float32 or 9007199254740993 read as a float64
+	ErrLossy = errors.New("conversion loses information")
+)
+
+// CoercionError is reported when coercion is on and a key defined in another
+// section can not be converted into the requested type
+type CoercionError struct {
+	Key   string
+	From  singleconfig.ConfigType // section the key is defined in
+	To    singleconfig.ConfigType // section matching the requested Go type
+	Value interface{}
+	Err   error // ErrOverflow, ErrLossy or the parse error
+}
+
+func (e *CoercionError) Error() string {
+	return fmt.Sprintf("key %s: can not coerce %s %v (%s) to %s (%s): %v",
+		e.Key, e.From.GoType(), e.Value, e.From, e.To.GoType(), e.To, e.Err)
+}
+
+func (e *CoercionError) Unwrap() error {
+	return e.Err
+}
+
+// coerceKey converts the value of key from the first of the sections found it
+// converts from, the error of the first section is reported if none does
+func coerceKey(values map[singleconfig.ConfigType]map[string]interface{}, key string, found []singleconfig.ConfigType, to singleconfig.ConfigType) (value interface{}, err error) {
+	var firstErr error
+	for _, from := range found {
+		if value, err = coerce(key, from, to, values[from][key]); err == nil {
+			return value, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// coerce converts value of the section from into the section to through its
+// text. Unless the value is a text, it has to convert back to the same value,
+// so nothing is rounded, truncated or reformatted on the way
+func coerce(key string, from, to singleconfig.ConfigType, value interface{}) (converted interface{}, err error) {
+	fail := func(err error) (interface{}, error) {
+		if errors.Is(err, strconv.ErrRange) {
+			err = ErrOverflow
+		}
+		return nil, &CoercionError{Key: key, From: from, To: to, Value: value, Err: err}
+	}
+	text, err := singleconfig.FormatValue(from, value)
+	if err != nil {
+		return fail(err)
+	}
+	if converted, err = singleconfig.ParseValue(to, text); err != nil {
+		if (to == singleconfig.CFG_UINT || to == singleconfig.CFG_UINT64) && strings.HasPrefix(text, "-") {
+			// a negative number is out of the range, not malformed
+			return fail(ErrOverflow)
+		}
+		return fail(err)
+	}
+	if isText(from) {
+		return converted, nil
+	}
+	backText, err := singleconfig.FormatValue(to, converted)
+	if err != nil {
+		return fail(err)
+	}
+	back, err := singleconfig.ParseValue(from, backText)
+	if err != nil || !reflect.DeepEqual(back, value) || !sameNumber(value, converted) {
+		return fail(ErrLossy)
+	}
+	return converted, nil
+}
+
+// isText reports whether the values of configType are texts, their text is
+// the value, so it is only parsed
+func isText(configType singleconfig.ConfigType) bool {
+	switch configType {
+	case singleconfig.CFG_STRING, singleconfig.CFG_STRINGLIST, singleconfig.CFG_STRINGLISTLIST, singleconfig.CFG_STRINGMAP:
+		return true
+	}
+	return false
+}
+
+// sameNumber reports whether the numbers value and converted are exactly
+// equal, their shortest texts can be equal while a float32 is not
+func sameNumber(value, converted interface{}) bool {
+	v, c := reflect.ValueOf(value), reflect.ValueOf(converted)
+	if !isNumber(v.Kind()) || !isNumber(c.Kind()) {
+		return true
+	}
+	return c.Convert(v.Type()).Interface() == value && v.Convert(c.Type()).Interface() == converted
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package multiconfig
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		from, to singleconfig.ConfigType
+		value    interface{}
+		want     interface{}
+		err      error // nil if the value converts
+	}{
+		{singleconfig.CFG_STRING, singleconfig.CFG_INT, "123456789", 123456789, nil},
+		{singleconfig.CFG_INT, singleconfig.CFG_FLOAT64, 3, float64(3), nil},
+		{singleconfig.CFG_FLOAT64, singleconfig.CFG_FLOAT32, 0.5, float32(0.5), nil},
+		{singleconfig.CFG_INT, singleconfig.CFG_UINT, -5, nil, ErrOverflow},
+		{singleconfig.CFG_INT64, singleconfig.CFG_UINT64, int64(-1), nil, ErrOverflow},
+		{singleconfig.CFG_UINT64, singleconfig.CFG_INT64, uint64(math.MaxUint64), nil, ErrOverflow},
+		{singleconfig.CFG_INT64, singleconfig.CFG_FLOAT64, int64(1<<53 + 1), nil, ErrLossy},
+		{singleconfig.CFG_FLOAT64, singleconfig.CFG_FLOAT32, 45.6, nil, ErrLossy},
+		{singleconfig.CFG_FLOAT64, singleconfig.CFG_INT, 1.5, nil, nil},
+	}
+	for _, test := range tests {
+		got, err := coerce("K", test.from, test.to, test.value)
+		switch {
+		case test.want != nil:
+			if err != nil || got != test.want {
+				t.Errorf("%s %v to %s = %#v, %v, want %#v", test.from, test.value, test.to, got, err, test.want)
+			}
+			continue
+		case err == nil:
+			t.Errorf("%s %v to %s = %#v, want an error", test.from, test.value, test.to, got)
+			continue
+		case test.err != nil && !errors.Is(err, test.err):
+			t.Errorf("%s %v to %s: %v, want %v", test.from, test.value, test.to, err, test.err)
+		}
+		var coercionErr *CoercionError
+		if !errors.As(err, &coercionErr) {
+			t.Errorf("%s %v to %s: %T is not a *CoercionError", test.from, test.value, test.to, err)
+			continue
+		}
+		msg := err.Error()
+		for _, name := range []string{test.from.GoType(), string(test.from), test.to.GoType(), string(test.to)} {
+			if !strings.Contains(msg, name) {
+				t.Errorf("%q does not name %s", msg, name)
+			}
+		}
+	}
+}
+
+func TestLookupCoercion(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	data := "[sectionInt]\nNEGATIVE = -5\n\n[sectionInt64]\nBIG = 9007199254740993\n\n[sectionFloat64]\nRATIO = 45.6\n\n[sectionString]\nCOUNT = 42\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var typeErr *TypeError
+	if _, err := Lookup[uint](m, "NEGATIVE"); !errors.As(err, &typeErr) {
+		t.Errorf("without coercion Lookup returned %v, want a *TypeError", err)
+	}
+
+	m.SetCoercion(true)
+	if _, err := Lookup[uint](m, "NEGATIVE"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Lookup[uint] of -5 returned %v, want ErrOverflow", err)
+	}
+	if _, err := Lookup[float64](m, "BIG"); !errors.Is(err, ErrLossy) {
+		t.Errorf("Lookup[float64] of 2^53+1 returned %v, want ErrLossy", err)
+	}
+	if _, err := Lookup[float32](m, "RATIO"); !errors.Is(err, ErrLossy) {
+		t.Errorf("Lookup[float32] of 45.6 returned %v, want ErrLossy", err)
+	}
+	if got, err := Lookup[int](m, "COUNT"); err != nil || got != 42 {
+		t.Errorf("Lookup[int] of \"42\" = %d, %v", got, err)
+	}
+}
@@ -0,0 +1,107 @@
+package multiconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// ConflictMode chooses what happens to keys defined in more than one section
+type ConflictMode int
+
+const (
+	CONFLICT_IGNORE ConflictMode = iota // conflicts are only reported by Conflicts
+	CONFLICT_WARN                       // conflicts are passed to the OnError listeners after every load
+	CONFLICT_ERROR                      // conflicts fail Validate, strict loads and reloads
+)
+
+// ConflictError describes a key defined in more than one typed section, in
+// one file or across layers
+type ConflictError struct {
+	Key     string
+	Origins []Origin // every definition, in layer and section order
+}
+
+func (e *ConflictError) Error() string {
+	places := make([]string, 0, len(e.Origins))
+	for _, origin := range e.Origins {
+		place := fmt.Sprintf("[%s] in %s", origin.Section, origin.File)
+		if origin.Line > 0 {
+			place = fmt.Sprintf("%s line %d", place, origin.Line)
+		}
+		places = append(places, place)
+	}
+	return fmt.Sprintf("key %s is defined in conflicting sections: %s", e.Key, strings.Join(places, ", "))
+}
+
+// ConflictErrors collects every conflicting key, sorted by key
+type ConflictErrors []*ConflictError
+
+func (e ConflictErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, conflictErr := range e {
+		msgs = append(msgs, conflictErr.Error())
+	}
+	return fmt.Sprintf("%d conflicting config key(s):\n\t%s", len(e), strings.Join(msgs, "\n\t"))
+}
+
+// Conflicts returns every key defined in more than one section, within a
+// file or across layers, whatever the ConflictMode is
+func (m *MultiConfig) Conflicts() ConflictErrors {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return findConflicts(m.multiConfig)
+}
+
+// SetConflictMode chooses how conflicting keys are handled, the default is
+// CONFLICT_IGNORE. The current conflicts are checked at once, CONFLICT_ERROR
+// returns them and CONFLICT_WARN passes them to the OnError listeners
+func (m *MultiConfig) SetConflictMode(mode ConflictMode) error {
+	m.lock.Lock()
+	m.conflictMode = mode
+	conflicts := findConflicts(m.multiConfig)
+	m.lock.Unlock()
+	if len(conflicts) == 0 {
+		return nil
+	}
+	switch mode {
+	case CONFLICT_WARN:
+		m.warnConflicts(conflicts)
+	case CONFLICT_ERROR:
+		return conflicts
+	}
+	return nil
+}
+
+// warnConflicts passes every conflict to the OnError listeners, the caller
+// does not hold m.lock
+func (m *MultiConfig) warnConflicts(conflicts ConflictErrors) {
+	for _, conflictErr := range conflicts {
+		m.notifyError(conflictErr)
+	}
+}
+
+// findConflicts returns the keys of layers defined in more than one section
+func findConflicts(layers []*singleconfig.SingleConfig) (conflicts ConflictErrors) {
+	origins := make(map[string][]Origin)
+	for _, layer := range layers {
+		for _, configType := range singleconfig.ConfigTypes {
+			for _, key := range layer.Keys(configType) {
+				raw, line, _ := layer.Definition(configType, key)
+				origins[key] = append(origins[key], Origin{File: layer.GetConfPath(), Line: line, Section: configType, Raw: raw})
+			}
+		}
+	}
+	for key, keyOrigins := range origins {
+		for _, origin := range keyOrigins[1:] {
+			if origin.Section != keyOrigins[0].Section {
+				conflicts = append(conflicts, &ConflictError{Key: key, Origins: keyOrigins})
+				break
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Key < conflicts[j].Key })
+	return conflicts
+}
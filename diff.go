@@ -0,0 +1,216 @@
+package multiconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around a change
+const diffContext = 3
+
+// diffOp is one line of a diff, kind is ' ', '-' or '+'
+type diffOp struct {
+	kind byte
+	text string // the line with its line break, the last one may have none
+}
+
+// unifiedDiff returns the unified diff turning before into after, "" if they
+// are equal. oldName and newName go into the --- and +++ header
+func unifiedDiff(oldName, newName string, before, after []byte) string {
+	if string(before) == string(after) {
+		return ""
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// find the next change and the end of its hunk
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last, unchanged := first, 0
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last, unchanged = i, 0
+			} else if unchanged++; unchanged > 2*diffContext {
+				break
+			}
+		}
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := last + diffContext + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+		writeHunk(buf, ops, from, to)
+		start = to
+	}
+	return buf.String()
+}
+
+// writeHunk writes ops[from:to] as one hunk with its @@ header
+func writeHunk(buf *strings.Builder, ops []diffOp, from, to int) {
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:from] {
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+	oldCount, newCount := 0, 0
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	// an empty range names the line before it
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+	for _, op := range ops[from:to] {
+		buf.WriteByte(op.kind)
+		buf.WriteString(op.text)
+		if !strings.HasSuffix(op.text, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+// splitLines splits data into lines that keep their line break
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxDiffEdits caps the number of changed lines diffLines looks for, its
+// memory grows with their square. Files differing more are shown as all of
+// their lines between the common head and tail replaced
+const maxDiffEdits = 1000
+
+// diffLines returns the shortest edit script turning a into b, the common
+// head and tail are taken off first
+func diffLines(a, b []string) (ops []diffOp) {
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+	for _, line := range a[:head] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ma, mb := a[head:len(a)-tail], b[head:len(b)-tail]
+	if middle, ok := myersDiff(ma, mb, maxDiffEdits); ok {
+		ops = append(ops, middle...)
+	} else {
+		for _, line := range ma {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range mb {
+			ops = append(ops, diffOp{'+', line})
+		}
+	}
+	for _, line := range a[len(a)-tail:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff returns the shortest edit script turning a into b with the
+// algorithm of Myers, ok is false if it needs more than maxEdits changes.
+// It takes O((len(a)+len(b))*D) time and O(D*D) memory for D changes
+func myersDiff(a, b []string, maxEdits int) (ops []diffOp, ok bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+	// v[offset+k] is the furthest x reached on diagonal k = x-y, trace[d]
+	// holds v[-d..d] after d changes for the way back
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1] // a line of b inserted
+			} else {
+				x = v[offset+k-1] + 1 // a line of a deleted
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		if n-m >= -d && n-m <= d && v[offset+n-m] >= n {
+			return myersOps(a, b, trace), true
+		}
+	}
+	return nil, false
+}
+
+// myersOps walks the furthest points of trace back from the end of a and b
+// and returns the edit script they describe
+func myersOps(a, b []string, trace [][]int) (ops []diffOp) {
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1] // diagonals -(d-1)..d-1
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x, y = x-1, y-1
+		}
+		if prevK == k+1 {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffOp{' ', a[x-1]})
+		x, y = x-1, y-1
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
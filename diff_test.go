@@ -0,0 +1,150 @@
+package multiconfig
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns the lines 1 to n, each ending with a line break
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%d\n", i+1)
+	}
+	return lines
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := numberedLines(20)
+	changed := func(changes map[int]string) []byte {
+		out := append([]string(nil), lines...)
+		for i, line := range changes {
+			out[i-1] = line
+		}
+		return []byte(strings.Join(out, ""))
+	}
+	before := []byte(strings.Join(lines, ""))
+	tests := []struct {
+		name   string
+		before []byte
+		after  []byte
+		want   string
+	}{
+		{
+			name:   "equal",
+			before: before,
+			after:  before,
+			want:   "",
+		},
+		{
+			name:   "one hunk",
+			before: before,
+			after:  changed(map[int]string{10: "ten\n"}),
+			want:   "--- a\n+++ b\n@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+ten\n 11\n 12\n 13\n",
+		},
+		{
+			name:   "merged hunk",
+			before: before,
+			after:  changed(map[int]string{5: "five\n", 11: "eleven\n"}),
+			want:   "--- a\n+++ b\n@@ -2,13 +2,13 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n 10\n-11\n+eleven\n 12\n 13\n 14\n",
+		},
+		{
+			name:   "two hunks",
+			before: before,
+			after:  changed(map[int]string{2: "two\n", 18: "eighteen\n"}),
+			want: "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			name:   "new file",
+			before: nil,
+			after:  []byte("a\nb\n"),
+			want:   "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:   "no newline at end of file",
+			before: []byte("a\nb"),
+			after:  []byte("a\nc"),
+			want:   "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+	for _, test := range tests {
+		if got := unifiedDiff("a", "b", test.before, test.after); got != test.want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b
+func lcsLength(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rnd.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(3)))
+		}
+		return lines
+	}
+	for trial := 0; trial < 2000; trial++ {
+		a, b := randomLines(), randomLines()
+		var gotA, gotB []string
+		changes := 0
+		for _, op := range diffLines(a, b) {
+			if op.kind != '+' {
+				gotA = append(gotA, op.text)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.text)
+			}
+			if op.kind != ' ' {
+				changes++
+			}
+		}
+		if !reflect.DeepEqual(gotA, a) && len(a) > 0 || !reflect.DeepEqual(gotB, b) && len(b) > 0 {
+			t.Fatalf("diff of %q and %q does not rebuild them: %q %q", a, b, gotA, gotB)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); changes != want {
+			t.Fatalf("diff of %q and %q has %d changes, want %d", a, b, changes, want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	a := numberedLines(20000)
+	b := append([]string{"first\n"}, a[1:len(a)-1]...)
+	b = append(b, "last\n")
+	ops := diffLines(a, b)
+	if len(ops) != len(a)+2 {
+		t.Errorf("%d operations for two changed lines of %d", len(ops), len(a))
+	}
+
+	// too many changes fall back to replacing every line
+	c := make([]string, len(a))
+	for i := range c {
+		c[i] = fmt.Sprintf("x%d\n", i)
+	}
+	if ops := diffLines(a, c); len(ops) != len(a)+len(c) {
+		t.Errorf("%d operations for a rewritten file", len(ops))
+	}
+}
@@ -0,0 +1,118 @@
+package multiconfig
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// envLayer overrides keys with environment variables, it sits on top of every file layer
+type envLayer struct {
+	prefix string
+	hints  map[string]singleconfig.ConfigType
+	values map[string]string // key without prefix -> raw value
+}
+
+// BindEnv reads every environment variable starting with prefix into a layer
+// on top of all files, etc: with prefix "APP" the variable APP_TEST_INT=40
+// overrides TEST_INT. Lists and maps may leave out the brackets like flags,
+// etc: APP_TEST_INTLIST=1,2,3. A value is parsed into each section its key
+// already has in the files, hints places keys the files do not define and
+// takes precedence over the files. Variables of unknown keys without a hint are
+// ignored. Values that can not be parsed are skipped and returned as a
+// singleconfig.ValueErrors, in strict mode a later reload fails on them.
+// Calling BindEnv again replaces the previous environment layer.
+func (m *MultiConfig) BindEnv(prefix string, hints map[string]singleconfig.ConfigType) error {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	env := &envLayer{
+		prefix: prefix,
+		hints:  make(map[string]singleconfig.ConfigType),
+		values: make(map[string]string),
+	}
+	for key, configType := range hints {
+		env.hints[key] = configType
+	}
+	for _, kv := range os.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv[:i], prefix) || i == len(prefix) {
+			continue
+		}
+		env.values[kv[len(prefix):i]] = kv[i+1:]
+	}
+
+	m.lock.Lock()
+	m.env = env
+	errs := m.rebuild()
+	m.lock.Unlock()
+	m.deliver()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// apply parses the environment values into values, which holds the merged files
+func (e *envLayer) apply(values map[singleconfig.ConfigType]map[string]interface{}, mapMerge MapMerge) (errs singleconfig.ValueErrors) {
+	keys := make([]string, 0, len(e.values))
+	for key := range e.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		raw := e.values[key]
+		for _, configType := range e.types(values, key) {
+			value, err := singleconfig.ParseValue(configType, bracketed(configType, raw))
+			if err != nil {
+				errs = append(errs, &singleconfig.ValueError{
+					File:    "env:" + e.prefix + key,
+					Section: configType,
+					Key:     key,
+					Raw:     raw,
+					Type:    configType.GoType(),
+					Err:     err,
+				})
+				continue
+			}
+			setValue(values, configType, key, value, mapMerge)
+		}
+	}
+	return errs
+}
+
+// types returns the sections an environment value of key is parsed into
+func (e *envLayer) types(values map[singleconfig.ConfigType]map[string]interface{}, key string) (types []singleconfig.ConfigType) {
+	if configType, ok := e.hints[key]; ok {
+		if values[configType] == nil {
+			values[configType] = make(map[string]interface{})
+		}
+		return []singleconfig.ConfigType{configType}
+	}
+	for _, configType := range singleconfig.ConfigTypes {
+		if _, ok := values[configType][key]; ok {
+			types = append(types, configType)
+		}
+	}
+	return types
+}
+
+// origin returns the environment variable that sets key in the section configType
+func (e *envLayer) origin(values map[singleconfig.ConfigType]map[string]interface{}, configType singleconfig.ConfigType, key string) (origin Origin, ok bool) {
+	raw, ok := e.values[key]
+	if !ok {
+		return origin, false
+	}
+	for _, t := range e.types(values, key) {
+		if t != configType {
+			continue
+		}
+		if _, err := singleconfig.ParseValue(configType, bracketed(configType, raw)); err != nil {
+			return origin, false
+		}
+		return Origin{File: "env:" + e.prefix + key, Section: configType, Raw: raw}, true
+	}
+	return origin, false
+}
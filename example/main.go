@@ -2,123 +2,92 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/UangDesign/multiconfig"
-)
-
-var (
-	TEST_INT             int
-	TEST_TEMP_INT        int
-	TEST_INT64           int64
-	TEST_TEMP_INT64      int64
-	TEST_STRING          string
-	TEST_TEMP_STRING     string
-	TEST_BOOL            bool
-	TEST_TEMP_BOOL_1     bool
-	TEST_TEMP_BOOL_0     bool
-	TEST_TEMP_BOOL_FALSE bool
-	TEST_STRINGLIST      []string
-	TEST_INTLIST         []int
-	TEST_FLOAT32         float32
-	TEST_FLOAT64         float64
-)
-
-var (
-	intMap        map[string]int
-	int64Map      map[string]int64
-	uintMap       map[string]uint
-	uint64Map     map[string]uint64
-	stringMap     map[string]string
-	boolMap       map[string]bool
-	stringListMap map[string][]string
-	intListMap    map[string][]int
-	float32Map    map[string]float32
-	float64Map    map[string]float64
+	"github.com/UangDesign/multiconfig/singleconfig"
 )
 
 var multiConfig *multiconfig.MultiConfig
 
 func init() {
-	multiConfig = multiconfig.NewMultiConfig("D:/go/src/multiconfig/example/config.conf", "D:/go/src/multiconfig/example/temp.conf")
+	var err error
+	multiConfig, err = multiconfig.LoadMultiConfig("D:/go/src/multiconfig/example/config.conf", "D:/go/src/multiconfig/example/temp.conf")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	//multiConfig = multiconfig.NewMultiConfig("D:/git/multiconfig/example/config.conf")
-	intMap = multiConfig.ParseInt()
-	int64Map = multiConfig.ParseInt64()
-	uintMap = multiConfig.ParseUint()
-	uint64Map = multiConfig.ParseUint64()
-	stringMap = multiConfig.ParseString()
-	boolMap = multiConfig.ParseBool()
-	stringListMap = multiConfig.ParseStringList()
-	intListMap = multiConfig.ParseIntList()
-	float32Map = multiConfig.ParseFloat32()
-	float64Map = multiConfig.ParseFloat64()
 }
 
 func outputConfig() {
-	for key, value := range intMap {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	for key, value := range int64Map {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	for key, value := range uintMap {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	for key, value := range uint64Map {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	for key, value := range stringMap {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	for key, value := range boolMap {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	for key, value := range stringListMap {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	for key, value := range intListMap {
-		fmt.Printf("k: %-40v v: %v\n", key, value)
-	}
-	// Specify the key output value
-	TEST_INT = intMap["TEST_INT"]
-	TEST_TEMP_INT = intMap["TEST_TEST_INT"]
-	TEST_INT64 = int64Map["TEST_INT64"]
-	TEST_TEMP_INT64 = int64Map["TEST_TEMP_INT64"]
-	TEST_STRING = stringMap["TEST_STRING"]
-	TEST_TEMP_STRING = stringMap["TEST_TEMP_STRING"]
-	TEST_BOOL = boolMap["TEST_BOOL"]
-	TEST_TEMP_BOOL_1 = boolMap["TEST_TEMP_BOOL_1"]
-	TEST_TEMP_BOOL_0 = boolMap["TEST_TEMP_BOOL_0"]
-	TEST_TEMP_BOOL_FALSE = boolMap["TEST_TEMP_BOOL_FALSE"]
-	TEST_STRINGLIST = stringListMap["TEST_STRINGLIST"]
-	TEST_INTLIST = intListMap["TEST_INTLIST"]
-	TEST_FLOAT32 = float32Map["TEST_FLOAT32"]
-	TEST_FLOAT64 = float64Map["TEST_FLOAT64"]
+	// Specify the key output value, a missing key falls back to the default
 	fmt.Printf(" TEST_INT: %v\n TEST_TEMP_INT:%v\n TEST_INT64:%v\n TEST_STRING:%v\n TEST_BOOL:%v\n TEST_STRINGLIST:%v\n TEST_INTLIST:%v\n TEST_FLOAT32:%v\n TEST_FLOAT64:%v\n",
-		TEST_INT,
-		TEST_TEMP_INT,
-		TEST_INT64,
-		TEST_STRING,
-		TEST_BOOL,
-		TEST_STRINGLIST,
-		TEST_INTLIST,
-		TEST_FLOAT32,
-		TEST_FLOAT64,
+		multiconfig.MustGet[int](multiConfig, "TEST_INT"),
+		multiconfig.GetOr(multiConfig, "TEST_TEMP_INT", 0),
+		multiconfig.GetOr[int64](multiConfig, "TEST_INT64", 0),
+		multiconfig.GetOr(multiConfig, "TEST_STRING", ""),
+		multiconfig.GetOr(multiConfig, "TEST_BOOL", false),
+		multiconfig.GetOr[[]string](multiConfig, "TEST_STRINGLIST", nil),
+		multiconfig.GetOr[[]int](multiConfig, "TEST_INTLIST", nil),
+		multiconfig.GetOr[float32](multiConfig, "TEST_FLOAT32", 0),
+		multiconfig.GetOr[float64](multiConfig, "TEST_FLOAT64", 0),
 	)
+	fmt.Printf(" TEST_DURATION:%v\n TEST_TIME:%v\n TEST_DURATIONLIST:%v\n TEST_BYTESIZE:%v\n",
+		multiconfig.GetOr(multiConfig, "TEST_DURATION", time.Minute),
+		multiconfig.GetOr(multiConfig, "TEST_TIME", time.Time{}),
+		multiconfig.GetOr[[]time.Duration](multiConfig, "TEST_DURATIONLIST", nil),
+		multiconfig.GetOr[singleconfig.ByteSize](multiConfig, "TEST_BYTESIZE", 0),
+	)
+	// a key in another section is reported instead of read as zero
+	if _, err := multiconfig.Lookup[string](multiConfig, "TEST_INT"); err != nil {
+		fmt.Println(err)
+	}
+	// with coercion TEST_STRING = 123456789 can be read as an int
+	multiConfig.SetCoercion(true)
+	fmt.Printf(" TEST_STRING as int:%v\n", multiconfig.GetOr(multiConfig, "TEST_STRING", 0))
+	multiConfig.SetCoercion(false)
+}
+
+func checkConflicts() {
+	// TEST_INTLIST is defined in [sectionInt] and [sectionIntList] of config.conf
+	for _, conflict := range multiConfig.Conflicts() {
+		fmt.Println(conflict)
+	}
+	// from now on conflicts are passed to the OnError listeners after every load
+	multiConfig.OnError(func(err error) {
+		fmt.Println("config:", err)
+	})
+	multiConfig.SetConflictMode(multiconfig.CONFLICT_WARN)
 }
 
 func SetConfig() {
+	before := multiconfig.GetOr(multiConfig, "TEST_INT", 0)
 	multiConfig.SetValue("TEST_INT", 38, "")
-	fmt.Printf("Change TEST_INT from %v to %v\n", TEST_INT, intMap["TEST_INT"])
+	fmt.Printf("Change TEST_INT from %v to %v\n", before, multiconfig.MustGet[int](multiConfig, "TEST_INT"))
 	// save config to conf
+	beforeList := multiconfig.GetOr[[]int](multiConfig, "TEST_INTLIST", nil)
 	multiConfig.SetValue("TEST_INTLIST", []int{7, 8, 9, 10, 11}, "")
-	fmt.Printf("Change TEST_INT from %v to %v\n", TEST_INTLIST, intListMap["TEST_INTLIST"])
+	fmt.Printf("Change TEST_INTLIST from %v to %v\n", beforeList, multiconfig.MustGet[[]int](multiConfig, "TEST_INTLIST"))
 	// Set sring
+	beforeString := multiconfig.GetOr(multiConfig, "TEST_STRING", "")
 	multiConfig.SetValue("TEST_STRING", "78911a", "")
-	fmt.Printf("Change TEST_INT from %v to %v\n", TEST_STRING, stringMap["TEST_STRING"])
+	fmt.Printf("Change TEST_STRING from %v to %v\n", beforeString, multiconfig.MustGet[string](multiConfig, "TEST_STRING"))
+	// review what a flush would write before writing it
+	for _, change := range multiConfig.Pending() {
+		fmt.Printf("pending %v [%v] %v: %q -> %q\n", change.File, change.Section, change.Key, change.Old, change.New)
+	}
+	if plans, err := multiConfig.PlanFlush(); err == nil {
+		for _, plan := range plans {
+			fmt.Print(plan.Diff)
+		}
+	}
 	multiConfig.FlushToConfig()
 }
 
 func main() {
+	checkConflicts()
 	outputConfig()
 	SetConfig()
 }
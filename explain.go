@@ -0,0 +1,65 @@
+package multiconfig
+
+import (
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// Origin is a layer that defines a key
+type Origin struct {
+	File    string // path of the file, env:PREFIX_KEY or flag:-KEY for the overlays
+	Line    int    // 1-based line in File, 0 when unknown
+	Section singleconfig.ConfigType
+	Raw     string // text of the value as written in the layer
+}
+
+// Explanation tells where the effective value of a key in one section comes from
+type Explanation struct {
+	Key string
+	Origin
+	// lower layers defining the key that Origin overrides, the closest first
+	Shadowed []Origin
+}
+
+// Explain reports, for every section the key is defined in, which layer the
+// effective value comes from and which lower layers it shadows, etc:
+// "TEST_INT is 322 from temp.conf line 4, shadowing config.conf line 12".
+// Values skipped because they can not be parsed do not count as a layer, the
+// maps of lower layers are listed as Shadowed even when their entries are
+// merged.
+// ErrKeyNotFound is returned when no layer defines the key
+func (m *MultiConfig) Explain(key string) (explanations []Explanation, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	files := buildSnapshot(m.multiConfig, m.mapMerge)
+	for _, configType := range singleconfig.ConfigTypes {
+		origins := make([]Origin, 0)
+		for _, layer := range m.multiConfig {
+			if raw, line, ok := layer.Lookup(configType, key); ok {
+				origins = append(origins, Origin{File: layer.GetConfPath(), Line: line, Section: configType, Raw: raw})
+			}
+		}
+		if m.env != nil {
+			if origin, ok := m.env.origin(files.values, configType, key); ok {
+				origins = append(origins, origin)
+			}
+		}
+		if m.flags != nil {
+			if origin, ok := m.flags.origin(configType, key); ok {
+				origins = append(origins, origin)
+			}
+		}
+		if len(origins) == 0 {
+			continue
+		}
+		explanation := Explanation{Key: key, Origin: origins[len(origins)-1]}
+		for i := len(origins) - 2; i >= 0; i-- {
+			explanation.Shadowed = append(explanation.Shadowed, origins[i])
+		}
+		explanations = append(explanations, explanation)
+	}
+	if len(explanations) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return explanations, nil
+}
@@ -0,0 +1,123 @@
+package multiconfig
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// flagLayer overrides keys with the flags set on the command line, it sits on top of every other layer
+type flagLayer struct {
+	flags []*flagValue
+}
+
+// flagValue is the flag.Value of one key
+type flagValue struct {
+	m          *MultiConfig
+	key        string
+	configType singleconfig.ConfigType
+	def        string
+	raw        string // text given on the command line
+	value      interface{}
+	set        bool
+}
+
+// BindFlags defines a flag on fs for every key of the merged configuration,
+// etc: -TEST_INT=38 or -TEST_STRINGLIST=a,b, the current value is the
+// default. Flags set while fs parses the command line override every other
+// layer. A key defined in several sections is bound to the first of them in
+// singleconfig.ConfigTypes, keys that already have a flag on fs are skipped.
+// Keys added to the files later do not get a flag.
+func (m *MultiConfig) BindFlags(fs *flag.FlagSet) {
+	snapshot := m.Snapshot()
+	layer := &flagLayer{}
+	bound := make(map[string]bool)
+	for _, configType := range singleconfig.ConfigTypes {
+		keys := snapshot.Keys(configType)
+		sort.Strings(keys)
+		for _, key := range keys {
+			if bound[key] || fs.Lookup(key) != nil {
+				continue
+			}
+			bound[key] = true
+			def, _ := singleconfig.FormatValue(configType, snapshot.values[configType][key])
+			v := &flagValue{m: m, key: key, configType: configType, def: def}
+			layer.flags = append(layer.flags, v)
+			fs.Var(v, key, fmt.Sprintf("`%s` value of %s from [%s]", configType.GoType(), key, configType))
+		}
+	}
+	m.lock.Lock()
+	m.flags = layer
+	m.rebuild()
+	m.lock.Unlock()
+	m.deliver()
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.m == nil {
+		// the zero value flag.PrintDefaults compares the default with
+		return ""
+	}
+	v.m.lock.Lock()
+	set, value := v.set, v.value
+	v.m.lock.Unlock()
+	if set {
+		raw, _ := singleconfig.FormatValue(v.configType, value)
+		return raw
+	}
+	return v.def
+}
+
+// Set parses a flag value, lists and maps may leave out the brackets:
+// -TEST_INTLIST=1,2,3 or -LABELS=region:us-east,tier:gold
+func (v *flagValue) Set(raw string) error {
+	value, err := singleconfig.ParseValue(v.configType, bracketed(v.configType, raw))
+	if err != nil {
+		return err
+	}
+	v.m.lock.Lock()
+	v.raw, v.value, v.set = raw, value, true
+	v.m.rebuild()
+	v.m.lock.Unlock()
+	v.m.deliver()
+	return nil
+}
+
+// bracketed adds the brackets a list or map given without them leaves out,
+// etc: 1,2,3 becomes [1,2,3]
+func bracketed(configType singleconfig.ConfigType, raw string) string {
+	if configType.IsList() && !strings.HasPrefix(raw, "[") {
+		return "[" + raw + "]"
+	}
+	if configType.IsMap() && !strings.HasPrefix(raw, "{") {
+		return "{" + raw + "}"
+	}
+	return raw
+}
+
+// IsBoolFlag lets a bool key be set with a bare -KEY
+func (v *flagValue) IsBoolFlag() bool {
+	return v.configType == singleconfig.CFG_BOOL
+}
+
+// apply puts the flags that were set on top of values
+func (l *flagLayer) apply(values map[singleconfig.ConfigType]map[string]interface{}, mapMerge MapMerge) {
+	for _, v := range l.flags {
+		if v.set {
+			setValue(values, v.configType, v.key, v.value, mapMerge)
+		}
+	}
+}
+
+// origin returns the flag that sets key in the section configType
+func (l *flagLayer) origin(configType singleconfig.ConfigType, key string) (origin Origin, ok bool) {
+	for _, v := range l.flags {
+		if v.set && v.key == key && v.configType == configType {
+			return Origin{File: "flag:-" + key, Section: configType, Raw: v.raw}, true
+		}
+	}
+	return origin, false
+}
@@ -0,0 +1,190 @@
+package multiconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// FlushReport tells what a flush did to every file, in layer order
+type FlushReport struct {
+	Changed    []string // files written
+	Unchanged  []string // files without changes or already up to date, they are not written
+	RolledBack []string // files written, then restored because a later file failed
+}
+
+// FlushError is returned when a file can not be written. Every file written
+// before it is restored, the ones that could not be are listed in Unrestored
+// and keep the new content
+type FlushError struct {
+	File       string
+	Err        error
+	Unrestored map[string]error
+}
+
+func (e *FlushError) Error() string {
+	msg := fmt.Sprintf("multiconfig: flush %s: %v", e.File, e.Err)
+	if len(e.Unrestored) == 0 {
+		return msg + ", no file changed"
+	}
+	files := make([]string, 0, len(e.Unrestored))
+	for file, err := range e.Unrestored {
+		files = append(files, fmt.Sprintf("%s (%v)", file, err))
+	}
+	sort.Strings(files)
+	return fmt.Sprintf("%s, could not restore %s", msg, strings.Join(files, ", "))
+}
+
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// FlushToConfig writes every file changed by SetValue or none, see Flush
+func (m *MultiConfig) FlushToConfig() (err error) {
+	_, err = m.Flush()
+	return err
+}
+
+// Flush writes every file changed by SetValue as one transaction: all new
+// contents are staged next to their files first, then put in place one by
+// one. If a file fails, the files already written get their old content
+// back and a *FlushError is returned. The report lists what happened to
+// every file
+func (m *MultiConfig) Flush() (report *FlushReport, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	report = &FlushReport{}
+	flushes := make([]*singleconfig.StagedFlush, 0, len(m.multiConfig))
+	defer func() {
+		// written or restored, the watcher must not take them for outside changes
+		written := make([]string, 0, len(flushes))
+		for _, flush := range flushes {
+			if flush.Changed() {
+				written = append(written, flush.File())
+			}
+		}
+		m.restamp(written)
+	}()
+	for _, singleConfig := range m.multiConfig {
+		if !singleConfig.Dirty() {
+			report.Unchanged = append(report.Unchanged, singleConfig.GetConfPath())
+			continue
+		}
+		flush, err := singleConfig.StageFlush()
+		if err != nil {
+			for _, staged := range flushes {
+				staged.Abort()
+			}
+			return report, &FlushError{File: singleConfig.GetConfPath(), Err: err}
+		}
+		flushes = append(flushes, flush)
+	}
+	for i, flush := range flushes {
+		if !flush.Changed() {
+			// the file already holds the values, only the changes are dropped
+			flush.Commit()
+			report.Unchanged = append(report.Unchanged, flush.File())
+			continue
+		}
+		if err := flush.Commit(); err != nil {
+			if flush.Committed() {
+				// renamed in place but not synced, it is restored like the others
+				report.Changed = append(report.Changed, flush.File())
+				i++
+			}
+			return report, rollbackFlush(report, flushes[:i], flushes[i:], &FlushError{File: flush.File(), Err: err})
+		}
+		report.Changed = append(report.Changed, flush.File())
+	}
+	return report, nil
+}
+
+// rollbackFlush restores the committed flushes newest first and drops the
+// pending ones
+func rollbackFlush(report *FlushReport, committed, pending []*singleconfig.StagedFlush, flushErr *FlushError) error {
+	for _, flush := range pending {
+		flush.Abort()
+	}
+	changed := report.Changed
+	report.Changed = nil
+	for i := len(committed) - 1; i >= 0; i-- {
+		flush := committed[i]
+		if !flush.Changed() {
+			continue
+		}
+		if err := flush.Rollback(); err != nil {
+			if flushErr.Unrestored == nil {
+				flushErr.Unrestored = make(map[string]error)
+			}
+			flushErr.Unrestored[flush.File()] = err
+			continue
+		}
+		// restored newest first, reported in layer order
+		report.RolledBack = append([]string{flush.File()}, report.RolledBack...)
+	}
+	for _, file := range changed {
+		if _, ok := flushErr.Unrestored[file]; ok {
+			report.Changed = append(report.Changed, file)
+		}
+	}
+	return flushErr
+}
+
+// Pending returns the values set since the last flush of every file, in
+// layer order, see singleconfig.Change
+func (m *MultiConfig) Pending() (changes []singleconfig.Change) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, singleConfig := range m.multiConfig {
+		changes = append(changes, singleConfig.Pending()...)
+	}
+	return changes
+}
+
+// FilePlan is what a flush would do to one file
+type FilePlan struct {
+	File string
+	Diff string // unified diff from the file to the flushed content, "" if it does not change
+}
+
+// PlanFlush returns, for every file in layer order, the unified diff between
+// its content and what FlushToConfig would write, nothing is written
+func (m *MultiConfig) PlanFlush() (plans []FilePlan, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, singleConfig := range m.multiConfig {
+		onDisk, flushed, err := singleConfig.PlanFlush()
+		if err != nil {
+			return nil, err
+		}
+		oldName, newName := diffName("a/", singleConfig.GetConfPath()), diffName("b/", singleConfig.GetConfPath())
+		if onDisk == nil {
+			oldName = "/dev/null"
+		}
+		plans = append(plans, FilePlan{File: singleConfig.GetConfPath(), Diff: unifiedDiff(oldName, newName, onDisk, flushed)})
+	}
+	return plans, nil
+}
+
+// diffName returns the name of filePath in a diff header. A path inside the
+// working directory is made relative to it and gets prefix the way git
+// writes it, etc: a/config.conf, any other path is kept as it is
+func diffName(prefix, filePath string) string {
+	if filepath.IsAbs(filePath) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return filePath
+		}
+		rel, err := filepath.Rel(wd, filePath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filePath
+		}
+		filePath = rel
+	}
+	return prefix + filepath.ToSlash(filePath)
+}
@@ -0,0 +1,181 @@
+package multiconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// loadTwoFiles loads a base file and a second one, both holding one int
+func loadTwoFiles(t *testing.T) (m *MultiConfig, base, second string) {
+	t.Helper()
+	dir := t.TempDir()
+	base, second = filepath.Join(dir, "base.conf"), filepath.Join(dir, "second.conf")
+	if err := os.WriteFile(base, []byte("[sectionInt]\nA = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("[sectionInt]\nB = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMultiConfig(base, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, base, second
+}
+
+func TestFlush(t *testing.T) {
+	m, base, second := loadTwoFiles(t)
+	if err := m.SetValue("A", 2, base); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetValue("B", 2, second); err != nil {
+		t.Fatal(err)
+	}
+	report, err := m.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{base, second}; !reflect.DeepEqual(report.Changed, want) {
+		t.Errorf("Changed = %q, want %q", report.Changed, want)
+	}
+	if len(report.RolledBack) != 0 || len(m.Pending()) != 0 {
+		t.Errorf("RolledBack = %q, %d changes pending", report.RolledBack, len(m.Pending()))
+	}
+	if data, _ := os.ReadFile(second); string(data) != "[sectionInt]\nB = 2\n" {
+		t.Errorf("%s holds %q", second, data)
+	}
+
+	report, err = m.Flush()
+	if err != nil || len(report.Changed) != 0 {
+		t.Errorf("a flush without changes wrote %q: %v", report.Changed, err)
+	}
+}
+
+func TestFlushRollback(t *testing.T) {
+	m, base, second := loadTwoFiles(t)
+	// the backup of the second file cannot be replaced, so its commit fails
+	m.SetBackups(1)
+	if err := os.MkdirAll(filepath.Join(second+".bak", "keep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetValue("A", 2, base); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetValue("B", 2, second); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.Flush()
+	var flushErr *FlushError
+	if !errors.As(err, &flushErr) {
+		t.Fatalf("Flush returned %v, want a *FlushError", err)
+	}
+	if flushErr.File != second || len(flushErr.Unrestored) != 0 {
+		t.Errorf("FlushError names %s, unrestored %v", flushErr.File, flushErr.Unrestored)
+	}
+	if len(report.Changed) != 0 || !reflect.DeepEqual(report.RolledBack, []string{base}) {
+		t.Errorf("Changed = %q, RolledBack = %q", report.Changed, report.RolledBack)
+	}
+	for filePath, want := range map[string]string{base: "[sectionInt]\nA = 1\n", second: "[sectionInt]\nB = 1\n"} {
+		if data, _ := os.ReadFile(filePath); string(data) != want {
+			t.Errorf("%s holds %q after the rollback, want %q", filePath, data, want)
+		}
+	}
+	if n := len(m.Pending()); n != 2 {
+		t.Errorf("%d changes pending after the rollback, want 2", n)
+	}
+
+	// once the backup can be written the same changes flush
+	if err := os.RemoveAll(second + ".bak"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(base); string(data) != "[sectionInt]\nA = 2\n" {
+		t.Errorf("%s holds %q", base, data)
+	}
+	if data, _ := os.ReadFile(second + ".bak"); string(data) != "[sectionInt]\nB = 1\n" {
+		t.Errorf("the backup holds %q", data)
+	}
+}
+
+func TestFlushConflict(t *testing.T) {
+	m, base, _ := loadTwoFiles(t)
+	if err := m.SetValue("A", 2, base); err != nil {
+		t.Fatal(err)
+	}
+	edited := "[sectionInt]\nA = 1\nEXTERNAL = 1\n"
+	if err := os.WriteFile(base, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.FlushToConfig(); !errors.Is(err, singleconfig.ErrFileChanged) {
+		t.Fatalf("Flush over an outside change returned %v", err)
+	}
+	if data, _ := os.ReadFile(base); string(data) != edited {
+		t.Errorf("the outside change was overwritten, %s holds %q", base, data)
+	}
+	if n := len(m.Pending()); n != 1 {
+		t.Errorf("%d changes pending after the conflict, want 1", n)
+	}
+
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := MustGet[int](m, "EXTERNAL"); got != 1 {
+		t.Errorf("EXTERNAL = %d after the reload", got)
+	}
+	if err := m.SetValue("A", 3, base); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.FlushToConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(base); string(data) != "[sectionInt]\nA = 3\nEXTERNAL = 1\n" {
+		t.Errorf("%s holds %q", base, data)
+	}
+}
+
+func TestPlanFlush(t *testing.T) {
+	m, base, second := loadTwoFiles(t)
+	if err := m.SetValue("B", 2, second); err != nil {
+		t.Fatal(err)
+	}
+	plans, err := m.PlanFlush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 2 || plans[0].File != base || plans[0].Diff != "" {
+		t.Fatalf("plans = %+v", plans)
+	}
+	// the temporary directory is outside the working directory
+	want := "--- " + second + "\n+++ " + second + "\n@@ -1,2 +1,2 @@\n [sectionInt]\n-B = 1\n+B = 2\n"
+	if plans[1].Diff != want {
+		t.Errorf("diff:\n%s\nwant\n%s", plans[1].Diff, want)
+	}
+	if data, _ := os.ReadFile(second); string(data) != "[sectionInt]\nB = 1\n" {
+		t.Errorf("PlanFlush wrote %q", data)
+	}
+}
+
+func TestDiffName(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"config.conf":                             "a/config.conf",
+		filepath.Join(wd, "conf", "app.conf"):     "a/conf/app.conf",
+		filepath.Join(filepath.Dir(wd), "x.conf"): filepath.Join(filepath.Dir(wd), "x.conf"),
+	}
+	for filePath, want := range tests {
+		if got := diffName("a/", filePath); got != want {
+			t.Errorf("diffName(%q) = %q, want %q", filePath, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package multiconfig
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Lookup returns the value of key from the section matching T, etc:
+// Lookup[time.Duration](m, "TIMEOUT") reads sectionDuration. It returns
+// ErrKeyNotFound if no layer defines key and a *TypeError if key is only
+// defined in other sections, or a *CoercionError if coercion is on and the
+// value can not be converted. Named types are matched like in Unmarshal
+func Lookup[T any](m *MultiConfig, key string) (value T, err error) {
+	if err = setField(m.Snapshot(), reflect.ValueOf(&value).Elem(), key); err != nil {
+		var zero T
+		return zero, err
+	}
+	return value, nil
+}
+
+// Get returns the value of key from the section matching T, ok is false if
+// the key is missing or defined under another type, use Lookup to tell apart
+func Get[T any](m *MultiConfig, key string) (value T, ok bool) {
+	value, err := Lookup[T](m, key)
+	return value, err == nil
+}
+
+// GetOr returns the value of key from the section matching T or def if the
+// key is missing or defined under another type
+func GetOr[T any](m *MultiConfig, key string, def T) T {
+	if value, ok := Get[T](m, key); ok {
+		return value
+	}
+	return def
+}
+
+// MustGet returns the value of key from the section matching T and panics
+// if the key is missing or defined under another type
+func MustGet[T any](m *MultiConfig, key string) T {
+	value, err := Lookup[T](m, key)
+	if err != nil {
+		panic(fmt.Errorf("multiconfig: get %s: %w", key, err))
+	}
+	return value
+}
@@ -1,11 +1,13 @@
 module github.com/UangDesign/multiconfig
 
-go 1.14
+go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/Unknwon/goconfig v0.0.0-20200908083735-df7de6a44db8
 	github.com/json-iterator/go v1.1.10
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
 	github.com/smartystreets/goconvey v1.6.4 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,193 +1,355 @@
 package multiconfig
 
-import "github.com/UangDesign/multiconfig/singleconfig"
+import (
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// MultiConfig is safe for concurrent use: readers get the current immutable
+// Snapshot without locking, writers are serialized and atomically swap in a
+// new snapshot after every SetValue or reload
 type MultiConfig struct {
-	multiConfig      []singleconfig.SingleConfig
-	configString     map[string]string
-	configBool       map[string]bool
-	configInt        map[string]int
-	configInt64      map[string]int64
-	configUint       map[string]uint
-	configUint64     map[string]uint64
-	configFloat32    map[string]float32
-	configFloat64    map[string]float64
-	configStringList map[string][]string
-	configIntList    map[string][]int
+	multiConfig  []*singleconfig.SingleConfig
+	strict       bool
+	lock         sync.Mutex   // serializes writers, guards everything but snapshot
+	snapshot     atomic.Value // holds the current Snapshot
+	watch        *watcher
+	env          *envLayer
+	flags        *flagLayer
+	mapMerge     MapMerge
+	coerce       bool
+	conflictMode ConflictMode
+	backups      int
+	onChange     []func(old, new Snapshot)
+	onError      []func(err error)
+	changes      []snapshotChange // queued for the OnChange listeners
+	delivering   bool             // whether a goroutine is passing changes to them
 }
 
+// MapMerge chooses how the map values of a key in several layers are combined
+type MapMerge int
+
+const (
+	MAP_MERGE_ENTRIES MapMerge = iota // a higher layer adds and overrides single entries
+	MAP_REPLACE                       // a higher layer replaces the whole map
+)
+
+// ErrNoConfigFile is returned by LoadMultiConfig when no file path is given
+var ErrNoConfigFile = errors.New("multiconfig: no configuration file given")
+
+// NewMultiConfig loads the given files in order, files that can not be loaded
+// are skipped, use LoadMultiConfig to find out why
 func NewMultiConfig(confPath string, moreConf ...string) (config *MultiConfig) {
 	if len(confPath) < 1 {
 		config = nil
 	} else {
-		config = &MultiConfig{
-			multiConfig:      make([]singleconfig.SingleConfig, 0),
-			configString:     make(map[string]string),
-			configBool:       make(map[string]bool),
-			configInt:        make(map[string]int),
-			configInt64:      make(map[string]int64),
-			configUint:       make(map[string]uint),
-			configUint64:     make(map[string]uint64),
-			configFloat32:    make(map[string]float32),
-			configFloat64:    make(map[string]float64),
-			configStringList: make(map[string][]string),
-			configIntList:    make(map[string][]int),
-		}
-		// confPath
-		oSingleConfig := singleconfig.NewSingleConfig(confPath)
-		if oSingleConfig != nil {
-			config.multiConfig = append(config.multiConfig, *oSingleConfig)
-		}
-		// moreConf
-		for i := range moreConf {
-			oSingleConfig := singleconfig.NewSingleConfig(moreConf[i])
-			if oSingleConfig != nil {
-				config.multiConfig = append(config.multiConfig, *oSingleConfig)
+		config = newMultiConfig()
+		for _, filePath := range append([]string{confPath}, moreConf...) {
+			if oSingleConfig := singleconfig.NewSingleConfig(filePath); oSingleConfig != nil {
+				config.multiConfig = append(config.multiConfig, oSingleConfig)
 			}
 		}
+		config.rebuild()
 	}
 	return config
 }
 
-func (m *MultiConfig) ParseString() map[string]string {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigString.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configString[k] = v
+// LoadMultiConfig loads the given files in order, later files override earlier ones.
+// The first file that can not be loaded is reported as a *singleconfig.ConfigError
+func LoadMultiConfig(confPath string, moreConf ...string) (config *MultiConfig, err error) {
+	if len(confPath) < 1 {
+		return nil, ErrNoConfigFile
+	}
+	config = newMultiConfig()
+	for _, filePath := range append([]string{confPath}, moreConf...) {
+		oSingleConfig, err := singleconfig.LoadSingleConfig(filePath)
+		if err != nil {
+			return nil, err
 		}
+		config.multiConfig = append(config.multiConfig, oSingleConfig)
 	}
-	return m.configString
+	config.rebuild()
+	return config, nil
 }
 
-func (m *MultiConfig) ParseBool() map[string]bool {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigBool.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configBool[k] = v
-		}
+// LoadStrictMultiConfig is LoadMultiConfig in strict mode, every value that
+// can not be parsed into the type of its section is reported in one
+// singleconfig.ValueErrors instead of being dropped
+func LoadStrictMultiConfig(confPath string, moreConf ...string) (config *MultiConfig, err error) {
+	if config, err = LoadMultiConfig(confPath, moreConf...); err != nil {
+		return nil, err
 	}
-	return m.configBool
+	config.strict = true
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-func (m *MultiConfig) ParseInt() map[string]int {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigInt.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configInt[k] = v
-		}
+func newMultiConfig() *MultiConfig {
+	return &MultiConfig{
+		multiConfig: make([]*singleconfig.SingleConfig, 0),
 	}
-	return m.configInt
 }
 
-func (m *MultiConfig) ParseInt64() map[string]int64 {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigInt64.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configInt64[k] = v
-		}
+// Snapshot returns the merged configuration as it is now. The snapshot never
+// changes, read several keys from one snapshot to get consistent values
+func (m *MultiConfig) Snapshot() Snapshot {
+	snapshot, _ := m.snapshot.Load().(Snapshot)
+	return snapshot
+}
+
+// rebuild merges the layers into a new snapshot, publishes it and queues the
+// change for the OnChange listeners, the caller holds m.lock and calls
+// deliver after releasing it. errs holds the overlay values that could not
+// be parsed and were skipped
+func (m *MultiConfig) rebuild() (errs singleconfig.ValueErrors) {
+	old := m.Snapshot()
+	new, errs := m.compose(m.multiConfig)
+	m.snapshot.Store(new)
+	if len(m.onChange) > 0 && !old.Equal(new) {
+		m.changes = append(m.changes, snapshotChange{old: old, new: new})
 	}
-	return m.configInt64
+	return errs
 }
 
-func (m *MultiConfig) ParseUint() map[string]uint {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigUint.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configUint[k] = v
-		}
+// compose merges layers and puts the overlays on top, the caller holds m.lock
+func (m *MultiConfig) compose(layers []*singleconfig.SingleConfig) (snapshot Snapshot, errs singleconfig.ValueErrors) {
+	snapshot = buildSnapshot(layers, m.mapMerge)
+	snapshot.coerce = m.coerce
+	if m.env != nil {
+		errs = append(errs, m.env.apply(snapshot.values, m.mapMerge)...)
 	}
-	return m.configUint
+	if m.flags != nil {
+		m.flags.apply(snapshot.values, m.mapMerge)
+	}
+	return snapshot, errs
+}
+
+func (m *MultiConfig) ParseString() map[string]string {
+	return m.Snapshot().ParseString()
+}
+
+func (m *MultiConfig) ParseBool() map[string]bool {
+	return m.Snapshot().ParseBool()
+}
+
+func (m *MultiConfig) ParseInt() map[string]int {
+	return m.Snapshot().ParseInt()
+}
+
+func (m *MultiConfig) ParseInt64() map[string]int64 {
+	return m.Snapshot().ParseInt64()
+}
+
+func (m *MultiConfig) ParseUint() map[string]uint {
+	return m.Snapshot().ParseUint()
 }
 
 func (m *MultiConfig) ParseUint64() map[string]uint64 {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigUint64.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configUint64[k] = v
-		}
-	}
-	return m.configUint64
+	return m.Snapshot().ParseUint64()
 }
 
 func (m *MultiConfig) ParseFloat32() map[string]float32 {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigFloat32.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configFloat32[k] = v
-		}
-	}
-	return m.configFloat32
+	return m.Snapshot().ParseFloat32()
 }
 
 func (m *MultiConfig) ParseFloat64() map[string]float64 {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigFloat64.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configFloat64[k] = v
-		}
-	}
-	return m.configFloat64
+	return m.Snapshot().ParseFloat64()
 }
 
 func (m *MultiConfig) ParseStringList() map[string][]string {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigStringList.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configStringList[k] = v
-		}
-	}
-	return m.configStringList
+	return m.Snapshot().ParseStringList()
 }
+
 func (m *MultiConfig) ParseIntList() map[string][]int {
-	for _, singleConfig := range m.multiConfig {
-		for k, v := range singleConfig.ConfigIntList.ParseConfig(singleConfig.GetConfigFile()) {
-			m.configIntList[k] = v
-		}
-	}
-	return m.configIntList
+	return m.Snapshot().ParseIntList()
+}
+
+func (m *MultiConfig) ParseDuration() map[string]time.Duration {
+	return m.Snapshot().ParseDuration()
+}
+
+func (m *MultiConfig) ParseTime() map[string]time.Time {
+	return m.Snapshot().ParseTime()
+}
+
+func (m *MultiConfig) ParseDurationList() map[string][]time.Duration {
+	return m.Snapshot().ParseDurationList()
+}
+
+func (m *MultiConfig) ParseByteSize() map[string]singleconfig.ByteSize {
+	return m.Snapshot().ParseByteSize()
+}
+
+func (m *MultiConfig) ParseIP() map[string]net.IP {
+	return m.Snapshot().ParseIP()
+}
+
+func (m *MultiConfig) ParseCIDR() map[string]*net.IPNet {
+	return m.Snapshot().ParseCIDR()
+}
+
+func (m *MultiConfig) ParseHostPort() map[string]singleconfig.HostPort {
+	return m.Snapshot().ParseHostPort()
+}
+
+func (m *MultiConfig) ParseURL() map[string]*url.URL {
+	return m.Snapshot().ParseURL()
+}
+
+func (m *MultiConfig) ParseIPList() map[string][]net.IP {
+	return m.Snapshot().ParseIPList()
+}
+
+func (m *MultiConfig) ParseCIDRList() map[string][]*net.IPNet {
+	return m.Snapshot().ParseCIDRList()
+}
+
+func (m *MultiConfig) ParseHostPortList() map[string][]singleconfig.HostPort {
+	return m.Snapshot().ParseHostPortList()
+}
+
+func (m *MultiConfig) ParseURLList() map[string][]*url.URL {
+	return m.Snapshot().ParseURLList()
+}
+
+func (m *MultiConfig) ParseStringMap() map[string]map[string]string {
+	return m.Snapshot().ParseStringMap()
+}
+
+func (m *MultiConfig) ParseIntMap() map[string]map[string]int {
+	return m.Snapshot().ParseIntMap()
+}
+
+func (m *MultiConfig) ParseStringListList() map[string][][]string {
+	return m.Snapshot().ParseStringListList()
+}
+
+func (m *MultiConfig) ParseIntListList() map[string][][]int {
+	return m.Snapshot().ParseIntListList()
+}
+
+func (m *MultiConfig) ParseInt64List() map[string][]int64 {
+	return m.Snapshot().ParseInt64List()
+}
+
+func (m *MultiConfig) ParseUint64List() map[string][]uint64 {
+	return m.Snapshot().ParseUint64List()
+}
+
+func (m *MultiConfig) ParseFloat64List() map[string][]float64 {
+	return m.Snapshot().ParseFloat64List()
+}
+
+func (m *MultiConfig) ParseBoolList() map[string][]bool {
+	return m.Snapshot().ParseBoolList()
+}
+
+// SetMapMerge chooses how map values of several layers are combined, the
+// default MAP_MERGE_ENTRIES merges them entry by entry
+func (m *MultiConfig) SetMapMerge(mapMerge MapMerge) {
+	m.lock.Lock()
+	m.mapMerge = mapMerge
+	m.rebuild()
+	m.lock.Unlock()
+	m.deliver()
+}
+
+// SetCoercion lets Get, Lookup and Unmarshal read a key defined in another
+// section if it converts losslessly, etc: TEST_STRING = 123456789 in
+// sectionString read as an int. It is off by default
+func (m *MultiConfig) SetCoercion(coerce bool) {
+	m.lock.Lock()
+	m.coerce = coerce
+	m.rebuild()
+	m.lock.Unlock()
+	m.deliver()
 }
 
 func (m *MultiConfig) SetValue(key string, value interface{}, filePath string) (err error) {
-	var valueType string
+	m.lock.Lock()
 	if filePath != "" {
 		for _, singleConfig := range m.multiConfig {
 			if singleConfig.GetConfPath() == filePath {
-				valueType, err = singleConfig.SetValue(key, value)
+				_, err = singleConfig.SetValue(key, value)
 			}
 		}
 	} else {
-		for _, singleConfig := range m.multiConfig {
-			if singleConfig.HasKey(key) {
-				valueType, err = singleConfig.SetValue(key, value)
-			}
+		for _, singleConfig := range m.keyLayers(key, value) {
+			_, err = singleConfig.SetValue(key, value)
 		}
 	}
-	m.reLoadConfig(valueType)
+	m.rebuild()
+	m.lock.Unlock()
+	m.deliver()
 	return err
 }
 
-func (m *MultiConfig) FlushToConfig() (err error) {
+// keyLayers returns the layers SetValue updates: the ones defining key in the
+// section of value, or if there are none the ones defining key at all
+func (m *MultiConfig) keyLayers(key string, value interface{}) (layers []*singleconfig.SingleConfig) {
+	if configType, ok := configTypeOf(reflect.TypeOf(value)); ok {
+		for _, singleConfig := range m.multiConfig {
+			if _, _, ok := singleConfig.Definition(configType, key); ok {
+				layers = append(layers, singleConfig)
+			}
+		}
+	}
+	if len(layers) > 0 {
+		return layers
+	}
 	for _, singleConfig := range m.multiConfig {
-		err = singleConfig.FlushToConfig()
-		if err != nil {
-			break
+		if singleConfig.HasKey(key) {
+			layers = append(layers, singleConfig)
 		}
 	}
-	return
-}
-
-func (m *MultiConfig) reLoadConfig(valueType string) {
-	switch valueType {
-	case "string":
-		m.ParseString()
-	case "bool":
-		m.ParseBool()
-	case "int":
-		m.ParseInt()
-	case "int64":
-		m.ParseInt64()
-	case "uint":
-		m.ParseUint()
-	case "uint64":
-		m.ParseUint64()
-	case "float32":
-		m.ParseFloat32()
-	case "float64":
-		m.ParseFloat64()
-	case "[]string":
-		m.ParseStringList()
-	case "[]int":
-		m.ParseIntList()
+	return layers
+}
+
+// Validate parses every value of every layer and overlay and returns all
+// invalid ones as a singleconfig.ValueErrors, it returns nil if the
+// configuration is clean. With CONFLICT_ERROR a clean configuration with
+// conflicting keys fails with a ConflictErrors
+func (m *MultiConfig) Validate() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.validateLayers(m.multiConfig)
+}
+
+// validateLayers checks layers and the overlays put on top of them, the caller holds m.lock
+func (m *MultiConfig) validateLayers(layers []*singleconfig.SingleConfig) error {
+	var errs singleconfig.ValueErrors
+	for _, singleConfig := range layers {
+		errs = append(errs, singleConfig.Check()...)
+	}
+	_, overlayErrs := m.compose(layers)
+	errs = append(errs, overlayErrs...)
+	if len(errs) > 0 {
+		return errs
+	}
+	if m.conflictMode == CONFLICT_ERROR {
+		if conflicts := findConflicts(layers); len(conflicts) > 0 {
+			return conflicts
+		}
+	}
+	return nil
+}
+
+// SetBackups makes FlushToConfig keep the last n versions of every file as
+// .bak, .bak.1 ... .bak.<n-1>, 0 keeps none
+func (m *MultiConfig) SetBackups(n int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.backups = n
+	for _, singleConfig := range m.multiConfig {
+		singleConfig.SetBackups(n)
 	}
 }
@@ -0,0 +1,24 @@
+package multiconfig
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSetValueError(t *testing.T) {
+	m, base, _ := loadTwoFiles(t)
+	for _, value := range []interface{}{nil, int32(2), []uint{2}, net.IP(nil)} {
+		if err := m.SetValue("A", value, ""); err == nil {
+			t.Errorf("SetValue(%T) is accepted", value)
+		}
+		if err := m.SetValue("A", value, base); err == nil {
+			t.Errorf("SetValue(%T) into %s is accepted", value, base)
+		}
+	}
+	if pending := m.Pending(); len(pending) != 0 {
+		t.Errorf("rejected values are pending: %v", pending)
+	}
+	if got := MustGet[int](m, "A"); got != 1 {
+		t.Errorf("A = %d", got)
+	}
+}
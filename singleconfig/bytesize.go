@@ -0,0 +1,89 @@
+package singleconfig
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ByteSize is a number of bytes, written with a SI or IEC unit, etc: 512KB, 10MiB, 2G
+type ByteSize uint64
+
+// byteUnits lists the units from the largest power down, SI before IEC of the same power
+var byteUnits = []struct {
+	name string
+	size uint64
+}{
+	{"EB", 1e18}, {"EiB", 1 << 60},
+	{"PB", 1e15}, {"PiB", 1 << 50},
+	{"TB", 1e12}, {"TiB", 1 << 40},
+	{"GB", 1e9}, {"GiB", 1 << 30},
+	{"MB", 1e6}, {"MiB", 1 << 20},
+	{"KB", 1e3}, {"KiB", 1 << 10},
+}
+
+// String writes b in the largest unit that holds it exactly, etc: 10MiB, 2GB
+// or 1500B, a SI unit is preferred over the IEC unit of the same power
+func (b ByteSize) String() string {
+	for _, unit := range byteUnits {
+		if b != 0 && uint64(b)%unit.size == 0 {
+			return strconv.FormatUint(uint64(b)/unit.size, 10) + unit.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+// parseByteSize reads a number followed by an optional unit. KB, MB.. are
+// powers of 1000, KiB, MiB.. powers of 1024, K, M.. are short for the SI
+// units. Units are case insensitive and the number may have a fraction as
+// long as the result is a whole number of bytes
+func parseByteSize(raw string) (ByteSize, error) {
+	raw = strings.TrimSpace(raw)
+	end := strings.IndexFunc(raw, func(r rune) bool {
+		return r != '.' && !unicode.IsDigit(r)
+	})
+	if end < 0 {
+		end = len(raw)
+	}
+	number, unit := raw[:end], strings.TrimSpace(raw[end:])
+	if number == "" {
+		return 0, fmt.Errorf("%q does not start with a number", raw)
+	}
+	size, ok := byteUnit(unit)
+	if !ok {
+		return 0, fmt.Errorf("unknown byte size unit %q", unit)
+	}
+	count, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return 0, fmt.Errorf("invalid number %q", number)
+	}
+	count.Mul(count, new(big.Rat).SetInt(new(big.Int).SetUint64(size)))
+	if !count.IsInt() {
+		return 0, fmt.Errorf("%q is not a whole number of bytes", raw)
+	}
+	if !count.Num().IsUint64() {
+		return 0, fmt.Errorf("%q overflows uint64", raw)
+	}
+	return ByteSize(count.Num().Uint64()), nil
+}
+
+func byteUnit(unit string) (size uint64, ok bool) {
+	unit = strings.ToUpper(unit)
+	switch len(unit) {
+	case 0:
+		return 1, true
+	case 1:
+		if unit == "B" {
+			return 1, true
+		}
+		unit += "B"
+	}
+	for _, byteUnit := range byteUnits {
+		if strings.ToUpper(byteUnit.name) == unit {
+			return byteUnit.size, true
+		}
+	}
+	return 0, false
+}
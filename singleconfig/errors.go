@@ -0,0 +1,136 @@
+package singleconfig
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ErrorKind tells why a configuration file could not be loaded
+type ErrorKind int
+
+const (
+	ERR_NOT_EXIST  ErrorKind = iota + 1 // the file does not exist
+	ERR_PERMISSION                      // the file can not be read by this process
+	ERR_IS_DIR                          // the path names a directory
+	ERR_SYNTAX                          // the file content can not be parsed
+	ERR_READ                            // any other I/O failure
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ERR_NOT_EXIST:
+		return "file does not exist"
+	case ERR_PERMISSION:
+		return "permission denied"
+	case ERR_IS_DIR:
+		return "is a directory"
+	case ERR_SYNTAX:
+		return "syntax error"
+	case ERR_READ:
+		return "read error"
+	}
+	return "unknown error"
+}
+
+// ConfigError is returned when a configuration file can not be loaded
+type ConfigError struct {
+	File    string    // path of the configuration file
+	Kind    ErrorKind // why the file was rejected
+	Line    int       // 1-based line of a syntax error, 0 if unknown
+	Content string    // text of the offending line, if any
+	Err     error     // underlying error
+}
+
+func (e *ConfigError) Error() string {
+	msg := fmt.Sprintf("config %s: %v", e.File, e.Kind)
+	if e.Line > 0 {
+		msg += fmt.Sprintf(" at line %d", e.Line)
+	}
+	if e.Content != "" {
+		msg += fmt.Sprintf(": %q", e.Content)
+	} else if e.Err != nil {
+		msg += fmt.Sprintf(": %v", e.Err)
+	}
+	return msg
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// syntaxError wraps a goconfig read error and locates the offending line in data
+func syntaxError(filePath string, data []byte, err error) *ConfigError {
+	configErr := &ConfigError{File: filePath, Kind: ERR_SYNTAX, Err: err}
+	const couldNotParse = "could not parse line: "
+	msg := err.Error()
+	if strings.HasPrefix(msg, couldNotParse) {
+		configErr.Content = strings.TrimPrefix(msg, couldNotParse)
+		configErr.Line = findLine(data, func(line string) bool {
+			return line == configErr.Content
+		})
+	} else {
+		// an empty section header makes goconfig reject the first key below it
+		blankSection := false
+		configErr.Line = findLine(data, func(line string) bool {
+			switch {
+			case line == "" || line[0] == '#' || line[0] == ';':
+				return false
+			case line[0] == '[' && line[len(line)-1] == ']':
+				blankSection = strings.TrimSpace(line[1:len(line)-1]) == ""
+				return false
+			}
+			return blankSection
+		})
+		configErr.Content = lineContent(data, configErr.Line)
+	}
+	return configErr
+}
+
+// findLine returns the 1-based number of the first trimmed line matching match
+func findLine(data []byte, match func(line string) bool) int {
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if match(strings.TrimSpace(string(line))) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ValueError describes a value that can not be parsed into the type of its section
+type ValueError struct {
+	File    string     // path of the configuration file
+	Section ConfigType // section the key is defined in
+	Key     string
+	Raw     string // text of the value as written in the file
+	Type    string // Go type the value should have been parsed into
+	Err     error
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("config %s: [%s] %s = %q is not a valid %s: %v", e.File, e.Section, e.Key, e.Raw, e.Type, e.Err)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// ValueErrors collects every invalid value found by a strict parse
+type ValueErrors []*ValueError
+
+func (e ValueErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, valueErr := range e {
+		msgs = append(msgs, valueErr.Error())
+	}
+	return fmt.Sprintf("%d invalid config value(s):\n\t%s", len(e), strings.Join(msgs, "\n\t"))
+}
+
+// lineContent returns the trimmed text of the 1-based line of data
+func lineContent(data []byte, line int) string {
+	lines := bytes.Split(data, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(string(lines[line-1]))
+}
@@ -0,0 +1,127 @@
+package singleconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// ErrFileChanged is returned by a flush of a file that changed on disk since
+// it was loaded or last flushed, writing it would lose the outside change.
+// Reload the file to pick the change up, the values not flushed are dropped
+var ErrFileChanged = errors.New("file changed on disk since it was loaded")
+
+// StagedFlush is a flush of one SingleConfig whose new content is written to
+// a temporary file but not yet put in place, so several files can be
+// committed together, see MultiConfig.FlushToConfig
+type StagedFlush struct {
+	s         *SingleConfig
+	data      []byte
+	staged    *stagedFile // nil when the file is already up to date
+	committed bool
+	// line model of the file before the commit, restored by Rollback
+	prevDoc     document
+	prevChanges map[string]map[string]*Change
+}
+
+// StageFlush renders the configuration and writes it next to the file, a
+// file whose content would not change is not staged. A file that changed on
+// disk since it was read is not overwritten, the error wraps ErrFileChanged
+func (s *SingleConfig) StageFlush() (flush *StagedFlush, err error) {
+	data, err := encodeConfig(s.format, s.doc)
+	if err != nil {
+		return nil, err
+	}
+	flush = &StagedFlush{s: s, data: data}
+	if flush.staged, err = stageFile(s.filePath, data, s.backups); err != nil {
+		return nil, err
+	}
+	if !flush.staged.existed || sha256.Sum256(flush.staged.old) != s.doc.sum {
+		flush.staged.abort()
+		return nil, fmt.Errorf("config %s: %w", s.filePath, ErrFileChanged)
+	}
+	if bytes.Equal(flush.staged.old, data) {
+		flush.staged.abort()
+		flush.staged = nil
+	}
+	return flush, nil
+}
+
+// File returns the path of the file the flush writes
+func (f *StagedFlush) File() string {
+	return f.s.filePath
+}
+
+// Changed reports whether the flush changes the file
+func (f *StagedFlush) Changed() bool {
+	return f.staged != nil
+}
+
+// Committed reports whether Commit put the new content in place, which is
+// the case when it only failed to sync the directory afterwards
+func (f *StagedFlush) Committed() bool {
+	return f.committed
+}
+
+// Commit puts the staged content in place
+func (f *StagedFlush) Commit() error {
+	if f.committed {
+		return nil
+	}
+	if f.staged == nil {
+		// the file already holds the values
+		f.s.changes = nil
+		return nil
+	}
+	renamed, err := f.staged.commit()
+	if !renamed {
+		return err
+	}
+	f.committed = true
+	f.prevChanges, f.s.changes = f.s.changes, nil
+	f.prevDoc = *f.s.doc
+	f.s.doc.reindex(f.s.format, f.s.filePath, f.data)
+	return err
+}
+
+// Abort drops a flush that is not committed
+func (f *StagedFlush) Abort() {
+	if f.staged != nil && !f.committed {
+		f.staged.abort()
+	}
+}
+
+// Rollback puts the content the file had before Commit back, the values
+// stay as they are and are written by the next flush
+func (f *StagedFlush) Rollback() error {
+	if !f.committed {
+		f.Abort()
+		return nil
+	}
+	if err := f.staged.rollback(); err != nil {
+		return err
+	}
+	f.committed = false
+	f.s.changes = f.prevChanges
+	*f.s.doc = f.prevDoc
+	return nil
+}
+
+// PlanFlush returns the content of the file and the content FlushToConfig
+// would write without writing anything, both are equal for a file without
+// changes. onDisk is nil for a file that does not exist
+func (s *SingleConfig) PlanFlush() (onDisk, flushed []byte, err error) {
+	if onDisk, err = ioutil.ReadFile(s.filePath); err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	if !s.Dirty() {
+		return onDisk, onDisk, nil
+	}
+	if flushed, err = encodeConfig(s.format, s.doc); err != nil {
+		return nil, nil, err
+	}
+	return onDisk, flushed, nil
+}
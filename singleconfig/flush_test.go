@@ -0,0 +1,191 @@
+package singleconfig
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of the flush tests")
+
+// flushGolden copies testdata/name to a temporary file, sets the values of
+// set and compares the flushed file with testdata/name.golden
+func flushGolden(t *testing.T, name string, set func(config *SingleConfig)) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set(config)
+	if err := config.FlushToConfig(); err != nil {
+		t.Fatal(err)
+	}
+	flushed, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(golden, flushed, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(flushed) != string(want) {
+		t.Errorf("flushed %s:\n%s\nwant\n%s", name, flushed, want)
+	}
+
+	// the flushed file reads back with the values set
+	reloaded, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, configType := range ConfigTypes {
+		for key, value := range config.Values(configType) {
+			if got, ok := reloaded.Values(configType)[key]; !ok || !reflect.DeepEqual(got, value) {
+				t.Errorf("[%s] %s = %v after the flush, want %v", configType, key, got, value)
+			}
+		}
+	}
+}
+
+// setValues calls SetValue for every key and value, in order
+func setValues(t *testing.T, config *SingleConfig, keyValues ...interface{}) {
+	t.Helper()
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if _, err := config.SetValue(keyValues[i].(string), keyValues[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFlushINI(t *testing.T) {
+	flushGolden(t, "flush.conf", func(config *SingleConfig) {
+		setValues(t, config,
+			"NAME", "new name", // keeps the comment above it
+			"PORT", 9090, // keeps key=value without spaces
+			"RETRIES", 5, // keeps the separator and its spacing
+			"REF", "%(BASE)s/v2", // written as is, URL keeps its reference
+			"PADDED", "  padded ",
+			"a=b", "x",
+			"LIST", []int{1, 2}, // first key of an empty section
+			"HOSTS", []string{"c.example.com"}, // replaces a multi-line list
+			"FLAG", true, // new section
+		)
+	})
+}
+
+func TestFlushYAML(t *testing.T) {
+	flushGolden(t, "flush.yaml", func(config *SingleConfig) {
+		setValues(t, config,
+			"server.port", 9090,
+			"NAME", "new name", // keeps the quotes and the comment
+			"tags", []string{"c"}, // keeps the flow style
+			"limits.max", 20,
+			"ENABLED", true,
+		)
+	})
+}
+
+func TestFlushTOML(t *testing.T) {
+	flushGolden(t, "flush.toml", func(config *SingleConfig) {
+		setValues(t, config,
+			"title", "new name",
+			"server.port", 9090,
+			"TEST_INT", 2,
+			"ENABLED", true,
+		)
+	})
+}
+
+func TestFlushINICRLF(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	data := "[sectionInt]\r\nA = 1\r\n\r\n[sectionString]\r\nS = x\r\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setValues(t, config, "A", 2, "B", 3, "FLAG", true)
+	if err := config.FlushToConfig(); err != nil {
+		t.Fatal(err)
+	}
+	flushed, _ := os.ReadFile(filePath)
+	want := "[sectionInt]\r\nA = 2\r\nB = 3\r\n\r\n[sectionString]\r\nS = x\r\n\r\n[sectionBool]\r\nFLAG = true\r\n"
+	if string(flushed) != want {
+		t.Errorf("flushed %q, want %q", flushed, want)
+	}
+	if strings.Count(string(flushed), "\n") != strings.Count(string(flushed), "\r\n") {
+		t.Error("a line break lost its CR")
+	}
+}
+
+func TestFlushUnchanged(t *testing.T) {
+	for _, name := range []string{"flush.conf", "flush.yaml", "flush.toml"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := LoadSingleConfig(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flushed, err := encodeConfig(config.format, config.doc); err != nil || string(flushed) != string(data) {
+			t.Errorf("%s without changes is written as %q: %v", name, flushed, err)
+		}
+	}
+}
+
+func TestFlushYAMLAnchor(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	data := "base: &base\n  port: 8080\ncopy: *base\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setValues(t, config, "copy.port", 9090)
+	if err := config.FlushToConfig(); err == nil || !strings.Contains(err.Error(), "anchor") {
+		t.Errorf("changing a value shared through an anchor returned %v", err)
+	}
+	if written, _ := os.ReadFile(filePath); string(written) != data {
+		t.Errorf("the file was changed to %q", written)
+	}
+}
+
+func TestFlushTOMLInlineTable(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.toml")
+	data := "server = { host = \"localhost\", port = 8080 }\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setValues(t, config, "server.port", 9090)
+	if err := config.FlushToConfig(); err == nil || !strings.Contains(err.Error(), "key = value") {
+		t.Errorf("changing a value of an inline table returned %v", err)
+	}
+	if written, _ := os.ReadFile(filePath); string(written) != data {
+		t.Errorf("the file was changed to %q", written)
+	}
+}
@@ -0,0 +1,630 @@
+package singleconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	util "github.com/UangDesign/multiconfig/utils"
+
+	"github.com/Unknwon/goconfig"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+)
+
+// fileFormat is the syntax of a configuration file, chosen by its extension
+type fileFormat int
+
+const (
+	formatINI fileFormat = iota
+	formatJSON
+	formatYAML
+	formatTOML
+)
+
+func formatOf(filePath string) fileFormat {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		return formatJSON
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	}
+	return formatINI
+}
+
+// document is a decoded configuration file, every value is stored in cfg
+// as the text an INI file would hold
+type document struct {
+	cfg *goconfig.ConfigFile
+	// nested keys every key of a YAML or TOML file was read from, section ->
+	// key -> path, etc: [sectionInt TEST_INT] or [server port]. A key is
+	// written back where it was read from
+	paths map[string]map[string][]string
+	// 1-based line of every key in the file, section -> key -> line
+	lines map[string]map[string]int
+	// line model of an INI file, nil for the other formats
+	ini *iniFile
+	// node tree of a YAML file, a flush changes it in place
+	yaml *yaml.Node
+	// line model of a TOML file
+	toml *tomlFile
+	// hash of the content the file was read from or last written with, a
+	// flush refuses to overwrite a file that changed since
+	sum [sha256.Size]byte
+}
+
+func newDocument() *document {
+	cfg, _ := goconfig.LoadFromReader(bytes.NewReader(nil))
+	return &document{cfg: cfg, paths: make(map[string]map[string][]string), lines: make(map[string]map[string]int)}
+}
+
+func (d *document) setPath(section, key string, path []string) {
+	if d.paths[section] == nil {
+		d.paths[section] = make(map[string][]string)
+	}
+	d.paths[section][key] = path
+}
+
+func (d *document) setLine(section, key string, line int) {
+	if d.lines[section] == nil {
+		d.lines[section] = make(map[string]int)
+	}
+	d.lines[section][key] = line
+}
+
+// line returns the line key of section was read from, 0 when it is unknown
+func (d *document) line(section, key string) int {
+	return d.lines[section][key]
+}
+
+// decodeConfig parses data in the given format
+func decodeConfig(format fileFormat, filePath string, data []byte) (doc *document, err error) {
+	switch format {
+	case formatJSON:
+		return decodeJSON(filePath, data)
+	case formatYAML:
+		return decodeYAML(filePath, data)
+	case formatTOML:
+		return decodeTOML(filePath, data)
+	}
+	doc = newDocument()
+	lines, _ := iniLogicalLines(data)
+	doc.cfg, err = goconfig.LoadFromReader(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, syntaxError(filePath, data, err)
+	}
+	doc.indexINI(data)
+	return doc, nil
+}
+
+// reindex rebuilds the line model of doc from data, the content the file
+// was just written with
+func (d *document) reindex(format fileFormat, filePath string, data []byte) {
+	d.sum = sha256.Sum256(data)
+	if format == formatINI {
+		d.indexINI(data)
+		return
+	}
+	if written, err := decodeConfig(format, filePath, data); err == nil {
+		d.paths, d.lines, d.yaml, d.toml = written.paths, written.lines, written.yaml, written.toml
+	}
+}
+
+// indexINI builds the line model of data, the INI text doc.cfg holds
+func (d *document) indexINI(data []byte) {
+	lines, starts := iniLogicalLines(data)
+	d.ini = newINIFile(data)
+	d.lines = make(map[string]map[string]int)
+	iniLines(d, lines, starts)
+}
+
+// iniLogicalLines joins the lines of a list or map value that goes on until
+// its brackets are closed, etc:
+//
+//	HOSTS = [
+//	    a.example.com,
+//	    b.example.com,
+//	]
+//
+// Only values of list and map sections are joined, and only if the brackets
+// close before the next section header or the end of the file, any other
+// line is kept as it is. starts holds the 1-based line every logical line
+// starts on
+func iniLogicalLines(data []byte) (lines []string, starts []int) {
+	physical := strings.Split(string(data), "\n")
+	section := ConfigType(goconfig.DEFAULT_SECTION)
+	for i := 0; i < len(physical); i++ {
+		line := physical[i]
+		lines, starts = append(lines, line), append(starts, i+1)
+		text := strings.TrimSpace(line)
+		if name, ok := iniHeader(text); ok {
+			section = ConfigType(name)
+			continue
+		}
+		if text == "" || text[0] == '#' || text[0] == ';' || !(section.IsList() || section.IsMap()) {
+			continue
+		}
+		_, start, ok := iniSplit(text)
+		if !ok {
+			continue
+		}
+		if last, ok := iniValueEnd(section, physical, i, text[start:]); ok {
+			for _, next := range physical[i+1 : last+1] {
+				lines[len(lines)-1] += " " + strings.TrimSpace(next)
+			}
+			i = last
+		}
+	}
+	return lines, starts
+}
+
+// iniValueEnd returns the index of the physical line the value starting on
+// line first closes its brackets on, ok is false if the value is not an open
+// list or map or its brackets do not close before the next section header
+func iniValueEnd(section ConfigType, physical []string, first int, value string) (last int, ok bool) {
+	if !strings.HasPrefix(value, "[") && !strings.HasPrefix(value, "{") {
+		return 0, false
+	}
+	depth := bracketDepth(value)
+	if depth <= 0 {
+		return 0, false
+	}
+	for last = first + 1; last < len(physical); last++ {
+		text := strings.TrimSpace(physical[last])
+		if name, ok := iniHeader(text); ok && (isTypedSection(name) || !isNestedList(section)) {
+			// a line of a nested list can look like a header, etc: [a]
+			return 0, false
+		}
+		if depth += bracketDepth(text); depth <= 0 {
+			return last, true
+		}
+	}
+	return 0, false
+}
+
+// iniHeader returns the name of a [section] line
+func iniHeader(text string) (name string, ok bool) {
+	if len(text) < 2 || text[0] != '[' || text[len(text)-1] != ']' {
+		return "", false
+	}
+	return strings.TrimSpace(text[1 : len(text)-1]), true
+}
+
+func isNestedList(configType ConfigType) bool {
+	return configType == CFG_STRINGLISTLIST || configType == CFG_INTLISTLIST
+}
+
+// bracketDepth returns how many more brackets text opens than it closes, quoted elements are skipped
+func bracketDepth(text string) (depth int) {
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// iniLines records the line of every key of an INI file and its lines in
+// the line model, a key defined twice keeps the line of the value goconfig
+// kept, the last one
+func iniLines(doc *document, lines []string, starts []int) {
+	section := goconfig.DEFAULT_SECTION
+	values := rawValues(doc.cfg, section)
+	for i, line := range lines {
+		text := strings.TrimSpace(line)
+		switch {
+		case text == "" || text[0] == '#' || text[0] == ';':
+			continue
+		case text[0] == '[' && strings.HasSuffix(text, "]"):
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			values = rawValues(doc.cfg, section)
+			doc.ini.setHeader(section, starts[i]-1)
+			continue
+		}
+		key, _, ok := iniSplit(text)
+		if !ok {
+			continue
+		}
+		if value, ok := values[key]; ok {
+			doc.setLine(section, key, starts[i])
+			span := iniSpan{first: starts[i] - 1, last: len(doc.ini.lines) - 1}
+			if i+1 < len(starts) {
+				span.last = starts[i+1] - 2
+			}
+			doc.ini.setSpan(section, key, value, span)
+		}
+	}
+}
+
+// encodeConfig renders doc in the given format
+func encodeConfig(format fileFormat, doc *document) (data []byte, err error) {
+	switch format {
+	case formatJSON:
+		return encodeJSON(doc.cfg)
+	case formatYAML:
+		return encodeYAML(doc)
+	case formatTOML:
+		return encodeTOML(doc)
+	}
+	if doc.ini != nil {
+		return doc.ini.render(doc.cfg)
+	}
+	buf := bytes.NewBuffer(nil)
+	err = goconfig.SaveConfigData(doc.cfg, buf)
+	return buf.Bytes(), err
+}
+
+// decodeJSON reads the typed layout {"sectionInt": {"TEST_INT": 37}, ...}
+func decodeJSON(filePath string, data []byte) (doc *document, err error) {
+	doc = newDocument()
+	cfg := doc.cfg
+	iter := jsoniter.ParseBytes(util.GetJsonIterator(), data)
+	if iter.WhatIsNext() != jsoniter.ObjectValue {
+		return nil, &ConfigError{File: filePath, Kind: ERR_SYNTAX, Err: fmt.Errorf("top level must be an object of sections")}
+	}
+	iter.ReadMapCB(func(iter *jsoniter.Iterator, section string) bool {
+		if iter.WhatIsNext() != jsoniter.ObjectValue {
+			err = fmt.Errorf("section %q must be an object", section)
+			return false
+		}
+		// make the section exist even though it does not have any key
+		cfg.SetValue(section, " ", " ")
+		return iter.ReadMapCB(func(iter *jsoniter.Iterator, key string) bool {
+			var raw string
+			if ConfigType(section).IsMap() && iter.WhatIsNext() == jsoniter.ObjectValue {
+				raw, err = readJSONMap(iter)
+			} else {
+				raw, err = readJSONValue(iter)
+			}
+			if err != nil {
+				err = fmt.Errorf("[%s] %s: %v", section, key, err)
+				return false
+			}
+			cfg.SetValue(section, key, raw)
+			return true
+		})
+	})
+	if err == nil {
+		err = iter.Error
+	}
+	if err != nil {
+		line := jsonErrorLine(data)
+		return nil, &ConfigError{File: filePath, Kind: ERR_SYNTAX, Line: line, Content: lineContent(data, line), Err: err}
+	}
+	jsonLines(doc, data)
+	return doc, nil
+}
+
+// jsonLines records the line of every key of the sections of a valid JSON
+// document, a key is a string followed by a colon two objects deep
+func jsonLines(doc *document, data []byte) {
+	var section string
+	depth, line := 0, 1
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\n':
+			line++
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case '"':
+			start := i
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			rest := bytes.TrimLeft(data[i+1:], " \t\r\n")
+			if len(rest) == 0 || rest[0] != ':' {
+				continue
+			}
+			var name string
+			if json.Unmarshal(data[start:i+1], &name) != nil {
+				continue
+			}
+			switch depth {
+			case 1:
+				section = name
+			case 2:
+				doc.setLine(section, name, line)
+			}
+		}
+	}
+}
+
+// readJSONValue converts a scalar or an array of scalars to its INI text
+func readJSONValue(iter *jsoniter.Iterator) (raw string, err error) {
+	switch iter.WhatIsNext() {
+	case jsoniter.StringValue:
+		return iter.ReadString(), nil
+	case jsoniter.NumberValue:
+		return string(iter.ReadNumber()), nil
+	case jsoniter.BoolValue:
+		return fmt.Sprintf("%v", iter.ReadBool()), nil
+	case jsoniter.ArrayValue:
+		seq := &sequence{}
+		iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+			var elem string
+			elemType := CFG_STRING
+			if iter.WhatIsNext() == jsoniter.ArrayValue {
+				elemType = CFG_STRINGLIST
+			}
+			if elem, err = readJSONValue(iter); err != nil {
+				return false
+			}
+			seq.add(elemType, elem)
+			return true
+		})
+		if err != nil {
+			return "", err
+		}
+		_, raw, err = seq.result()
+		return raw, err
+	case jsoniter.NilValue:
+		iter.Skip()
+		return "", fmt.Errorf("null is not a value")
+	case jsoniter.ObjectValue:
+		iter.Skip()
+		return "", fmt.Errorf("objects are not supported")
+	}
+	return "", fmt.Errorf("invalid value")
+}
+
+// readJSONMap converts an object of scalars to the INI text of a map
+func readJSONMap(iter *jsoniter.Iterator) (raw string, err error) {
+	entries := make(map[string]string)
+	iter.ReadMapCB(func(iter *jsoniter.Iterator, key string) bool {
+		var value string
+		if iter.WhatIsNext() == jsoniter.ArrayValue {
+			iter.Skip()
+			err = fmt.Errorf("arrays are not supported in a map")
+			return false
+		}
+		if value, err = readJSONValue(iter); err != nil {
+			return false
+		}
+		entries[key] = value
+		return true
+	})
+	return joinMap(entries), err
+}
+
+// jsonErrorLine returns the 1-based line of the first JSON syntax error in data
+func jsonErrorLine(data []byte) int {
+	var v interface{}
+	if syntaxErr, ok := json.Unmarshal(data, &v).(*json.SyntaxError); ok {
+		return bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+	}
+	return 0
+}
+
+// encodeJSON writes cfg back in the typed layout, keeping the order of sections and keys
+func encodeJSON(cfg *goconfig.ConfigFile) (data []byte, err error) {
+	buf := bytes.NewBufferString("{")
+	for i, section := range cfg.GetSectionList() {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		sectionName, _ := util.GetJsonIterator().Marshal(section)
+		fmt.Fprintf(buf, "\n  %s: {", sectionName)
+		values, _ := cfg.GetSection(section)
+		first := true
+		for _, key := range cfg.GetKeyList(section) {
+			raw, ok := values[key]
+			if !ok {
+				continue
+			}
+			if !first {
+				buf.WriteString(",")
+			}
+			first = false
+			keyName, _ := util.GetJsonIterator().Marshal(key)
+			value, err := marshalJSON(jsonValue(ConfigType(section), raw))
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(buf, "\n    %s: %s", keyName, value)
+		}
+		if !first {
+			buf.WriteString("\n  ")
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("\n}\n")
+	return buf.Bytes(), nil
+}
+
+// marshalJSON writes value as JSON, maps are written by hand with their keys sorted
+func marshalJSON(value interface{}) (data []byte, err error) {
+	entries := make(map[string]interface{})
+	switch v := value.(type) {
+	case map[string]string:
+		for k, entry := range v {
+			entries[k] = entry
+		}
+	case map[string]int:
+		for k, entry := range v {
+			entries[k] = entry
+		}
+	default:
+		return util.GetJsonIterator().Marshal(value)
+	}
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := bytes.NewBufferString("{")
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := util.GetJsonIterator().Marshal(k)
+		entry, err := util.GetJsonIterator().Marshal(entries[k])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(buf, "%s:%s", key, entry)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// jsonValue returns the typed value of raw, or raw itself when the section is
+// not typed or the value can not be parsed
+func jsonValue(configType ConfigType, raw string) interface{} {
+	value, err := ParseValue(configType, raw)
+	if err != nil {
+		return raw
+	}
+	switch configType {
+	case CFG_STRING, CFG_BOOL, CFG_INT, CFG_INT64, CFG_UINT, CFG_UINT64, CFG_FLOAT32, CFG_FLOAT64,
+		CFG_STRINGLIST, CFG_INTLIST, CFG_TIME, CFG_STRINGMAP, CFG_INTMAP, CFG_STRINGLISTLIST, CFG_INTLISTLIST,
+		CFG_INT64LIST, CFG_UINT64LIST, CFG_FLOAT64LIST, CFG_BOOLLIST:
+		if isFinite(value) {
+			return value
+		}
+	}
+	// JSON has no type for the value, keep its text, etc: "30s" instead of
+	// the nanoseconds, and write a list as an array of texts
+	text, _ := FormatValue(configType, value)
+	if configType.IsList() {
+		elems, _ := splitList(text)
+		return elems
+	}
+	return text
+}
+
+// isFinite reports whether value holds no infinite or NaN float, JSON has
+// no number for them
+func isFinite(value interface{}) bool {
+	switch v := value.(type) {
+	case float32:
+		return isFinite(float64(v))
+	case float64:
+		return !math.IsInf(v, 0) && !math.IsNaN(v)
+	case []float64:
+		for _, f := range v {
+			if !isFinite(f) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sequence collects the elements of a JSON or TOML array or a YAML sequence
+type sequence struct {
+	texts []string
+	types []ConfigType
+}
+
+// add appends an element, raw is its INI text and configType its section
+func (s *sequence) add(configType ConfigType, raw string) {
+	s.texts = append(s.texts, raw)
+	s.types = append(s.types, configType)
+}
+
+// result returns the section and INI text of the sequence. Ints make a
+// []int, numbers with a float a []float64, bools a []bool, a sequence of
+// sequences a nested list, anything else a []string
+func (s *sequence) result() (configType ConfigType, raw string, err error) {
+	lists, ints, floats, bools := 0, true, true, true
+	elems := make([]string, 0, len(s.texts))
+	for i, elemType := range s.types {
+		switch {
+		case elemType == CFG_STRINGLISTLIST || elemType == CFG_INTLISTLIST:
+			return "", "", fmt.Errorf("lists are nested more than two levels deep")
+		case elemType.IsList():
+			lists++
+			if elemType != CFG_INTLIST && s.texts[i] != "[]" {
+				ints = false
+			}
+			elems = append(elems, s.texts[i])
+		default:
+			ints = ints && elemType == CFG_INT
+			floats = floats && (elemType == CFG_INT || elemType == CFG_FLOAT64)
+			bools = bools && elemType == CFG_BOOL
+			elems = append(elems, quoteElem(s.texts[i], ",]"))
+		}
+	}
+	raw = fmt.Sprintf("[%v]", strings.Join(elems, ","))
+	switch {
+	case lists > 0 && lists < len(elems):
+		return "", "", fmt.Errorf("a list can not mix lists and values")
+	case lists > 0 && ints:
+		return CFG_INTLISTLIST, raw, nil
+	case lists > 0:
+		return CFG_STRINGLISTLIST, raw, nil
+	case len(elems) > 0 && ints:
+		return CFG_INTLIST, raw, nil
+	case len(elems) > 0 && floats:
+		return CFG_FLOAT64LIST, raw, nil
+	case len(elems) > 0 && bools:
+		return CFG_BOOLLIST, raw, nil
+	}
+	return CFG_STRINGLIST, raw, nil
+}
+
+// isTypedSection reports whether name is the name of a typed section
+func isTypedSection(name string) bool {
+	for _, configType := range ConfigTypes {
+		if string(configType) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sameValue reports whether a and b are the same value of the section, etc:
+// 1 and 1.0 of sectionFloat64
+func sameValue(configType ConfigType, a, b string) bool {
+	return canonical(configType, a) == canonical(configType, b)
+}
+
+func canonical(configType ConfigType, raw string) string {
+	if value, err := ParseValue(configType, raw); err == nil {
+		if text, err := FormatValue(configType, value); err == nil {
+			return text
+		}
+	}
+	return raw
+}
+
+// isNative reports whether a value of the section can be written with the
+// native types of YAML and TOML and read back into the same section
+func isNative(configType ConfigType) bool {
+	switch configType {
+	case CFG_STRING, CFG_BOOL, CFG_INT, CFG_FLOAT64, CFG_STRINGLIST, CFG_INTLIST, CFG_TIME, CFG_STRINGLISTLIST, CFG_INTLISTLIST,
+		CFG_FLOAT64LIST, CFG_BOOLLIST:
+		return true
+	}
+	return false
+}
+
+// formatNativeFloat formats f so it is never mistaken for an integer
+func formatNativeFloat(f float64, bitSize int) string {
+	raw := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(raw, ".eEnN") {
+		raw += ".0"
+	}
+	return raw
+}
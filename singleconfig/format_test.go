@@ -0,0 +1,107 @@
+package singleconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestINILogicalLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		lines  []string
+		starts []int
+	}{
+		{
+			name:   "joined list",
+			data:   "[sectionStringList]\nHOSTS = [\n    a,\n    b,\n]\nNEXT = [c]",
+			lines:  []string{"[sectionStringList]", "HOSTS = [ a, b, ]", "NEXT = [c]"},
+			starts: []int{1, 2, 6},
+		},
+		{
+			name:   "joined map",
+			data:   "[sectionStringMap]\nM = {\n  a: b,\n}",
+			lines:  []string{"[sectionStringMap]", "M = { a: b, }"},
+			starts: []int{1, 2},
+		},
+		{
+			name:   "string section",
+			data:   "[sectionString]\nS = [\nx]",
+			lines:  []string{"[sectionString]", "S = [", "x]"},
+			starts: []int{1, 2, 3},
+		},
+		{
+			name:   "unclosed at the end of the file",
+			data:   "[sectionStringList]\nL = [a,\nb",
+			lines:  []string{"[sectionStringList]", "L = [a,", "b"},
+			starts: []int{1, 2, 3},
+		},
+		{
+			name:   "unclosed before a header",
+			data:   "[sectionStringList]\nL = [a,\n[sectionInt]\nN = 1",
+			lines:  []string{"[sectionStringList]", "L = [a,", "[sectionInt]", "N = 1"},
+			starts: []int{1, 2, 3, 4},
+		},
+		{
+			name:   "nested list",
+			data:   "[sectionStringListList]\nL = [\n[a]\n[b],\n]",
+			lines:  []string{"[sectionStringListList]", "L = [ [a] [b], ]"},
+			starts: []int{1, 2},
+		},
+	}
+	for _, test := range tests {
+		lines, starts := iniLogicalLines([]byte(test.data))
+		if !reflect.DeepEqual(lines, test.lines) || !reflect.DeepEqual(starts, test.starts) {
+			t.Errorf("%s: got %q %v, want %q %v", test.name, lines, starts, test.lines, test.starts)
+		}
+	}
+}
+
+func TestMultiLineListFlush(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	data := "[sectionStringList]\n# hosts\nHOSTS = [\n    a.example.com,\n    \"b,c\",\n]\n\n[sectionString]\nNAME = x\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Values(CFG_STRINGLIST)["HOSTS"]; !reflect.DeepEqual(got, []string{"a.example.com", "b,c"}) {
+		t.Fatalf("HOSTS = %q", got)
+	}
+	if _, line, _ := config.Lookup(CFG_STRINGLIST, "HOSTS"); line != 3 {
+		t.Errorf("HOSTS is reported on line %d, want 3", line)
+	}
+
+	if _, err := config.SetValue("NAME", "y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.FlushToConfig(); err != nil {
+		t.Fatal(err)
+	}
+	flushed, _ := os.ReadFile(filePath)
+	if want := strings.Replace(data, "NAME = x", "NAME = y", 1); string(flushed) != want {
+		t.Errorf("an unchanged multi-line list was rewritten:\n%s", flushed)
+	}
+
+	if _, err := config.SetValue("HOSTS", []string{"d", "e f"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.FlushToConfig(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Values(CFG_STRINGLIST)["HOSTS"]; !reflect.DeepEqual(got, []string{"d", "e f"}) {
+		t.Errorf("HOSTS = %q after the flush", got)
+	}
+	if got := reloaded.Values(CFG_STRING)["NAME"]; got != "y" {
+		t.Errorf("NAME = %v after the flush", got)
+	}
+}
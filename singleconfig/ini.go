@@ -0,0 +1,211 @@
+package singleconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Unknwon/goconfig"
+)
+
+// iniFile is the line model of an INI file. A write starts from the lines
+// as read, so comments, blank lines and the order of keys survive it: a
+// changed value only replaces the lines of its key, a new key goes after the
+// last key of its section and a new section is appended to the file
+type iniFile struct {
+	lines    []string // physical lines without their line break
+	eol      string   // "\r" if the file uses CRLF line breaks
+	trailing bool     // whether the file ends with a line break
+	// lines of every key, section -> key -> span, a key defined twice keeps
+	// the span of the value goconfig kept, the last one
+	spans map[string]map[string]iniSpan
+	// index of the line after which a new key of the section is inserted
+	ends map[string]int
+	// values as read, a key whose value is unchanged keeps its lines as is
+	values map[string]map[string]string
+}
+
+// iniSpan holds the 0-based first and last physical line of a key
+type iniSpan struct {
+	first, last int
+}
+
+func newINIFile(data []byte) *iniFile {
+	ini := &iniFile{
+		lines:  strings.Split(string(data), "\n"),
+		spans:  make(map[string]map[string]iniSpan),
+		ends:   make(map[string]int),
+		values: make(map[string]map[string]string),
+	}
+	if n := len(ini.lines); ini.lines[n-1] == "" {
+		ini.lines, ini.trailing = ini.lines[:n-1], true
+	}
+	if len(ini.lines) > 0 && strings.HasSuffix(ini.lines[0], "\r") {
+		ini.eol = "\r"
+	}
+	return ini
+}
+
+// setSpan records the lines key of section is defined on and its value
+func (ini *iniFile) setSpan(section, key, value string, span iniSpan) {
+	if ini.spans[section] == nil {
+		ini.spans[section] = make(map[string]iniSpan)
+		ini.values[section] = make(map[string]string)
+	}
+	ini.spans[section][key] = span
+	ini.values[section][key] = value
+	ini.ends[section] = span.last
+}
+
+// setHeader records the [section] line, new keys go after it until the
+// section has a key
+func (ini *iniFile) setHeader(section string, index int) {
+	ini.ends[section] = index
+}
+
+// render writes cfg over the lines as read. A key or value holding a line
+// break is rejected, goconfig would read the rest as a line of its own
+func (ini *iniFile) render(cfg *goconfig.ConfigFile) (data []byte, err error) {
+	out := make([]string, 0, len(ini.lines))
+	sections := cfg.GetSectionList()
+	values := make(map[string]map[string]string, len(sections))
+	for _, section := range sections {
+		values[section] = rawValues(cfg, section)
+		for key, value := range values[section] {
+			if strings.ContainsAny(section+key+value, "\r\n") {
+				return nil, fmt.Errorf("[%s] %s: a line break can not be written to an INI file", section, key)
+			}
+		}
+	}
+	if _, ok := ini.ends[goconfig.DEFAULT_SECTION]; !ok {
+		// keys before the first section
+		out = append(out, ini.newKeys(cfg, goconfig.DEFAULT_SECTION, values[goconfig.DEFAULT_SECTION])...)
+	}
+	starts := make(map[int][2]string)
+	for section, keys := range ini.spans {
+		for key, span := range keys {
+			starts[span.first] = [2]string{section, key}
+		}
+	}
+	inserts := make(map[int][]string)
+	for _, section := range sections {
+		if end, ok := ini.ends[section]; ok {
+			inserts[end] = append(inserts[end], section)
+		}
+	}
+	for i := 0; i < len(ini.lines); i++ {
+		if sectionKey, ok := starts[i]; ok {
+			section, key := sectionKey[0], sectionKey[1]
+			span := ini.spans[section][key]
+			value, ok := values[section][key]
+			switch {
+			case !ok:
+				// deleted key
+			case value == ini.values[section][key]:
+				out = append(out, ini.lines[span.first:span.last+1]...)
+			default:
+				out = append(out, ini.replaceValue(ini.lines[span.first], value))
+			}
+			i = span.last
+		} else {
+			out = append(out, ini.lines[i])
+		}
+		for _, section := range inserts[i] {
+			out = append(out, ini.newKeys(cfg, section, values[section])...)
+		}
+	}
+	for _, section := range sections {
+		if _, ok := ini.ends[section]; ok || section == goconfig.DEFAULT_SECTION {
+			continue
+		}
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, ini.eol)
+		}
+		out = append(out, "["+section+"]"+ini.eol)
+		out = append(out, ini.newKeys(cfg, section, values[section])...)
+	}
+	text := strings.Join(out, "\n")
+	if ini.trailing {
+		text += "\n"
+	}
+	return []byte(text), nil
+}
+
+// newKeys returns the lines of the keys of section that are not in the file,
+// values holds the raw values of section
+func (ini *iniFile) newKeys(cfg *goconfig.ConfigFile, section string, values map[string]string) (lines []string) {
+	for _, key := range cfg.GetKeyList(section) {
+		if _, ok := ini.spans[section][key]; ok {
+			continue
+		}
+		lines = append(lines, iniKey(key)+" = "+iniValue(values[key])+ini.eol)
+	}
+	return lines
+}
+
+// rawValues returns the values of section as written, a %(KEY)s reference
+// is kept instead of expanded
+func rawValues(cfg *goconfig.ConfigFile, section string) map[string]string {
+	values, _ := cfg.GetSection(section)
+	return values
+}
+
+// replaceValue puts value into the line of a key, the key, the separator and
+// the spacing around it stay as written
+func (ini *iniFile) replaceValue(line, value string) string {
+	_, start, ok := iniSplit(line)
+	if !ok {
+		return line
+	}
+	return line[:start] + iniValue(value) + ini.eol
+}
+
+// iniSplit returns the key of a key line the way goconfig reads it, a key
+// holding = or : is quoted, and the index its value starts at
+func iniSplit(line string) (key string, valueStart int, ok bool) {
+	text := strings.TrimLeft(line, " \t")
+	offset := len(line) - len(text)
+	quoted := false
+	for _, quote := range []string{`"""`, `"`, "`"} {
+		if strings.HasPrefix(text, quote) {
+			end := strings.Index(text[len(quote):], quote)
+			if end < 0 {
+				return "", 0, false
+			}
+			key, quoted = text[len(quote):len(quote)+end], true
+			offset += len(quote) + end + len(quote)
+			text = text[len(quote)+end+len(quote):]
+			break
+		}
+	}
+	sep := strings.IndexAny(text, "=:")
+	if sep < 0 || sep == 0 && !quoted {
+		return "", 0, false
+	}
+	if !quoted {
+		key = strings.TrimSpace(text[:sep])
+	}
+	rest := text[sep+1:]
+	return key, offset + sep + 1 + len(rest) - len(strings.TrimLeft(rest, " \t")), true
+}
+
+// iniKey quotes key the way goconfig reads it back
+func iniKey(key string) string {
+	if !strings.ContainsAny(key, "=:") {
+		return key
+	}
+	if !strings.Contains(key, "`") {
+		return "`" + key + "`"
+	}
+	if !strings.Contains(key, `"`) {
+		return `"` + key + `"`
+	}
+	return `"""` + key + `"""`
+}
+
+// iniValue quotes value the way goconfig reads it back
+func iniValue(value string) string {
+	if strings.HasPrefix(value, "`") || strings.HasPrefix(value, `"""`) || value != strings.TrimSpace(value) {
+		return `"""` + value + `"""`
+	}
+	return value
+}
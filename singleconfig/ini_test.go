@@ -0,0 +1,76 @@
+package singleconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestINILineBreak(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	data := "[sectionString]\nK = a\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"a\nB = evil", "a\rB = evil"} {
+		if _, err := config.SetValue("K", value); err != nil {
+			t.Fatal(err)
+		}
+		if err := config.FlushToConfig(); err == nil {
+			t.Errorf("%q was flushed", value)
+		}
+	}
+	if _, err := config.SetValue("NEW\nKEY", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := config.PlanFlush(); err == nil {
+		t.Error("a key with a line break is planned")
+	}
+	if written, _ := os.ReadFile(filePath); string(written) != data {
+		t.Errorf("the file was changed to %q", written)
+	}
+}
+
+func TestINIReplaceValue(t *testing.T) {
+	ini := &iniFile{}
+	tests := []struct {
+		line, value, want string
+	}{
+		{"KEY = old", "new", "KEY = new"},
+		{"KEY=old", "new", "KEY=new"},
+		{"  KEY :   old", "new", "  KEY :   new"},
+		{"`a=b` = old", "new", "`a=b` = new"},
+		{"KEY = old", " padded", `KEY = """ padded"""`},
+		{"KEY = old", "`tick", "KEY = \"\"\"`tick\"\"\""},
+	}
+	for _, test := range tests {
+		if got := ini.replaceValue(test.line, test.value); got != test.want {
+			t.Errorf("replaceValue(%q, %q) = %q, want %q", test.line, test.value, got, test.want)
+		}
+	}
+	crlf := &iniFile{eol: "\r"}
+	if got := crlf.replaceValue("KEY = old\r", "new"); got != "KEY = new\r" {
+		t.Errorf("replaceValue of a CRLF line = %q", got)
+	}
+}
+
+func TestINIKey(t *testing.T) {
+	tests := map[string]string{
+		"KEY":    "KEY",
+		"a=b":    "`a=b`",
+		"a:`b":   `"a:` + "`" + `b"`,
+		"a=`\"b": `"""a=` + "`" + `"b"""`,
+	}
+	for key, want := range tests {
+		if got := iniKey(key); got != want {
+			t.Errorf("iniKey(%q) = %q, want %q", key, got, want)
+		}
+		if got, _, ok := iniSplit(want + " = x"); !ok || got != key {
+			t.Errorf("iniSplit(%q) read the key %q", want+" = x", got)
+		}
+	}
+}
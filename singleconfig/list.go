@@ -0,0 +1,280 @@
+package singleconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The list grammar:
+//
+//	list  = "[" [ elem { "," elem } ] [ "," ] "]"
+//	elem  = list | quoted | bare
+//	map   = "{" [ entry { "," entry } ] [ "," ] "}"
+//	entry = ( quoted | bare ) ":" ( quoted | bare )
+//
+// A quoted element is a Go string literal, etc: "a,b" or "say \"hi\"", and ""
+// is the empty string. A bare element runs up to the next separator and is
+// trimmed, an empty bare element is skipped, so [a,,b,] is [a,b].
+
+// listParser reads one list or map from raw
+type listParser struct {
+	raw string
+	pos int
+}
+
+func (p *listParser) skipSpace() {
+	for p.pos < len(p.raw) && unicode.IsSpace(rune(p.raw[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *listParser) peek() byte {
+	if p.pos < len(p.raw) {
+		return p.raw[p.pos]
+	}
+	return 0
+}
+
+// text reads a quoted or bare element, a bare one ends before any byte of stops
+func (p *listParser) text(stops string) (text string, quoted bool, err error) {
+	p.skipSpace()
+	if p.peek() != '"' {
+		start := p.pos
+		for p.pos < len(p.raw) && !strings.ContainsRune(stops, rune(p.raw[p.pos])) {
+			p.pos++
+		}
+		return strings.TrimSpace(p.raw[start:p.pos]), false, nil
+	}
+	start := p.pos
+	for p.pos++; p.pos < len(p.raw) && p.raw[p.pos] != '"'; p.pos++ {
+		if p.raw[p.pos] == '\\' {
+			p.pos++
+		}
+	}
+	if p.pos >= len(p.raw) {
+		return "", true, fmt.Errorf("unterminated quoted element %s", p.raw[start:])
+	}
+	p.pos++
+	if text, err = strconv.Unquote(p.raw[start:p.pos]); err != nil {
+		return "", true, fmt.Errorf("invalid quoted element %s", p.raw[start:p.pos])
+	}
+	return text, true, nil
+}
+
+// separator consumes the ',' after an element and reports whether close ends the list
+func (p *listParser) separator(close byte) (end bool, err error) {
+	p.skipSpace()
+	switch p.peek() {
+	case ',':
+		p.pos++
+		return false, nil
+	case close:
+		p.pos++
+		return true, nil
+	case 0:
+		return false, fmt.Errorf("missing %q", close)
+	}
+	return false, fmt.Errorf("unexpected %q at offset %d, quote elements holding it", p.peek(), p.pos)
+}
+
+// list reads a list, every element is a string or a nested []interface{}
+func (p *listParser) list() (elems []interface{}, err error) {
+	p.skipSpace()
+	if p.peek() != '[' {
+		return nil, fmt.Errorf("%q is not a [..] list", p.raw)
+	}
+	p.pos++
+	elems = make([]interface{}, 0)
+	for {
+		p.skipSpace()
+		if p.peek() == ']' {
+			p.pos++
+			return elems, nil
+		}
+		if p.peek() == '[' {
+			nested, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, nested)
+		} else {
+			text, quoted, err := p.text(",]")
+			if err != nil {
+				return nil, err
+			}
+			if text != "" || quoted {
+				elems = append(elems, text)
+			}
+		}
+		if end, err := p.separator(']'); err != nil || end {
+			return elems, err
+		}
+	}
+}
+
+// entries reads a map
+func (p *listParser) entries() (entries map[string]string, err error) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return nil, fmt.Errorf("%q is not a {..} map", p.raw)
+	}
+	p.pos++
+	entries = make(map[string]string)
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return entries, nil
+		}
+		key, quoted, err := p.text(":,}")
+		if err != nil {
+			return nil, err
+		}
+		if key == "" && !quoted && p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if p.skipSpace(); p.peek() != ':' {
+			return nil, fmt.Errorf("map entry %q has no ':'", key)
+		}
+		p.pos++
+		if key == "" && !quoted {
+			return nil, fmt.Errorf("map entry without a key at offset %d", p.pos)
+		}
+		value, _, err := p.text(",}")
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = value
+		if end, err := p.separator('}'); err != nil || end {
+			return entries, err
+		}
+	}
+}
+
+// end fails unless only white space follows
+func (p *listParser) end() error {
+	if p.skipSpace(); p.pos < len(p.raw) {
+		return fmt.Errorf("unexpected %q after the end", p.raw[p.pos:])
+	}
+	return nil
+}
+
+// splitList returns the elements of a flat [a,b,c] list
+func splitList(raw string) (elems []string, err error) {
+	p := &listParser{raw: raw}
+	tree, err := p.list()
+	if err == nil {
+		err = p.end()
+	}
+	if err != nil {
+		return nil, err
+	}
+	elems = make([]string, 0, len(tree))
+	for _, elem := range tree {
+		text, ok := elem.(string)
+		if !ok {
+			return nil, fmt.Errorf("nested list %v in a flat list, quote it", elem)
+		}
+		elems = append(elems, text)
+	}
+	return elems, nil
+}
+
+// eachListElem calls parse with every element of a [a,b,c] list and stops at the first error
+func eachListElem(raw string, parse func(elem string) error) error {
+	elems, err := splitList(raw)
+	if err != nil {
+		return err
+	}
+	return eachElem(elems, parse)
+}
+
+// eachElem calls parse with every element of an already split list
+func eachElem(elems []string, parse func(elem string) error) error {
+	for _, elem := range elems {
+		if err := parse(elem); err != nil {
+			return fmt.Errorf("list element %q: %w", elem, err)
+		}
+	}
+	return nil
+}
+
+// formatList writes list as a [a,b,c] list, format converts every element
+func formatList[T any](list []T, format func(T) string) string {
+	elems := make([]string, 0, len(list))
+	for _, elem := range list {
+		elems = append(elems, format(elem))
+	}
+	return joinList(elems)
+}
+
+// splitNestedList returns the elements of every list of a [[a,b],[c]] list
+func splitNestedList(raw string) (lists [][]string, err error) {
+	p := &listParser{raw: raw}
+	tree, err := p.list()
+	if err == nil {
+		err = p.end()
+	}
+	if err != nil {
+		return nil, err
+	}
+	lists = make([][]string, 0, len(tree))
+	for _, elem := range tree {
+		nested, ok := elem.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("element %q is not a list", elem)
+		}
+		list := make([]string, 0, len(nested))
+		for _, nestedElem := range nested {
+			text, ok := nestedElem.(string)
+			if !ok {
+				return nil, fmt.Errorf("lists are nested more than two levels deep")
+			}
+			list = append(list, text)
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// splitMap returns the entries of a {k:v, k2:v2} map, a repeated key keeps its last value
+func splitMap(raw string) (entries map[string]string, err error) {
+	p := &listParser{raw: raw}
+	if entries, err = p.entries(); err == nil {
+		err = p.end()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// quoteElem quotes text when it would not be read back as itself
+func quoteElem(text, specials string) string {
+	if text == "" || strings.TrimSpace(text) != text || text[0] == '"' || text[0] == '[' || text[0] == '{' ||
+		strings.ContainsAny(text, specials) || strconv.Quote(text) != `"`+text+`"` {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// joinList writes elems as a [a,b,c] list, quoting them as needed
+func joinList(elems []string) string {
+	quoted := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		quoted = append(quoted, quoteElem(elem, ",]"))
+	}
+	return fmt.Sprintf("[%v]", strings.Join(quoted, ","))
+}
+
+// joinNestedList writes lists as a [[a,b],[c]] list
+func joinNestedList(lists [][]string) string {
+	joined := make([]string, 0, len(lists))
+	for _, list := range lists {
+		joined = append(joined, joinList(list))
+	}
+	return fmt.Sprintf("[%v]", strings.Join(joined, ","))
+}
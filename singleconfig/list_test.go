@@ -0,0 +1,148 @@
+package singleconfig
+
+import (
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"[]", []string{}},
+		{"[ ]", []string{}},
+		{"[a]", []string{"a"}},
+		{"[a,b,c]", []string{"a", "b", "c"}},
+		{"[ aa,  bb,  cc , ]", []string{"aa", "bb", "cc"}},
+		{"[a,,b,]", []string{"a", "b"}},
+		{`["a,b", c]`, []string{"a,b", "c"}},
+		{`[""]`, []string{""}},
+		{`["say \"hi\"", "x]"]`, []string{`say "hi"`, "x]"}},
+		{"[hello world]", []string{"hello world"}},
+	}
+	for _, test := range tests {
+		got, err := splitList(test.raw)
+		if err != nil {
+			t.Errorf("splitList(%q): %v", test.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitList(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestSplitListErrors(t *testing.T) {
+	for _, raw := range []string{"", "a,b", "[a", "[a]b", `["a]`, "[[a]]", "[a:b]x"} {
+		if got, err := splitList(raw); err == nil {
+			t.Errorf("splitList(%q) = %q, want an error", raw, got)
+		}
+	}
+}
+
+func TestSplitNestedList(t *testing.T) {
+	got, err := splitNestedList(`[[a,b], [], ["c,d"]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"a", "b"}, {}, {"c,d"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitNestedList = %q, want %q", got, want)
+	}
+	if _, err := splitNestedList("[a,[b]]"); err == nil {
+		t.Error("a bare element in a nested list is accepted")
+	}
+}
+
+func TestSplitMap(t *testing.T) {
+	got, err := splitMap(`{region:us-east, "a:b":"c,d", tier : gold,}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"region": "us-east", "a:b": "c,d", "tier": "gold"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitMap = %q, want %q", got, want)
+	}
+	for _, raw := range []string{"{a}", "{a:b", "[a:b]"} {
+		if _, err := splitMap(raw); err == nil {
+			t.Errorf("splitMap(%q) is accepted", raw)
+		}
+	}
+}
+
+// TestListRoundTrip writes every list and map section with FormatValue and
+// reads it back with ParseValue
+func TestListRoundTrip(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	u, _ := url.Parse("https://example.com/api?q=1,2")
+	tests := []struct {
+		configType ConfigType
+		value      interface{}
+	}{
+		{CFG_STRINGLIST, []string{}},
+		{CFG_STRINGLIST, []string{"a", "b,c", "", " d ", `say "hi"`, "[x]", "{y}"}},
+		{CFG_INTLIST, []int{7, -8, 0}},
+		{CFG_INT64LIST, []int64{math.MinInt64, math.MaxInt64}},
+		{CFG_UINT64LIST, []uint64{0, math.MaxUint64}},
+		{CFG_FLOAT64LIST, []float64{1.5, -2, 1e300}},
+		{CFG_BOOLLIST, []bool{true, false}},
+		{CFG_DURATIONLIST, []time.Duration{500 * time.Millisecond, time.Hour}},
+		{CFG_STRINGLISTLIST, [][]string{{"a", "b"}, {}, {"c,d"}}},
+		{CFG_INTLISTLIST, [][]int{{1, 2}, {3}}},
+		{CFG_STRINGMAP, map[string]string{"region": "us-east", "a:b": "c,d", "e": ""}},
+		{CFG_INTMAP, map[string]int{"tenant1": 100, "tenant2": -250}},
+		{CFG_IPLIST, []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("::1")}},
+		{CFG_CIDRLIST, []*net.IPNet{ipNet}},
+		{CFG_HOSTPORTLIST, []HostPort{{Host: "example.com", Port: 443}, {Host: "::1", Port: 80}}},
+		{CFG_URLLIST, []*url.URL{u}},
+	}
+	for _, test := range tests {
+		raw, err := FormatValue(test.configType, test.value)
+		if err != nil {
+			t.Errorf("FormatValue(%s, %v): %v", test.configType, test.value, err)
+			continue
+		}
+		got, err := ParseValue(test.configType, raw)
+		if err != nil {
+			t.Errorf("ParseValue(%s, %q): %v", test.configType, raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.value) {
+			t.Errorf("%s: %#v was written as %q and read back as %#v", test.configType, test.value, raw, got)
+		}
+	}
+}
+
+func TestParseEmptyList(t *testing.T) {
+	for _, configType := range ConfigTypes {
+		if !configType.IsList() {
+			continue
+		}
+		value, err := parseLenient(configType, "[]")
+		if err != nil {
+			t.Errorf("%s: [] is not an empty list: %v", configType, err)
+			continue
+		}
+		if v := reflect.ValueOf(value); v.Kind() != reflect.Slice || v.Len() != 0 {
+			t.Errorf("%s: [] is read as %#v", configType, value)
+		}
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	value, err := parseLenient(CFG_INTLIST, "[1,x,3]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(value, []int{1, 3}) {
+		t.Errorf("parseLenient kept %v, want [1 3]", value)
+	}
+	if _, err := ParseValue(CFG_INTLIST, "[1,x,3]"); err == nil {
+		t.Error("ParseValue accepts an invalid element")
+	}
+}
@@ -0,0 +1,54 @@
+package singleconfig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func parseStringMap(raw string) (map[string]string, error) {
+	return splitMap(raw)
+}
+
+func parseIntMap(raw string) (ret map[string]int, err error) {
+	entries, err := splitMap(raw)
+	if err != nil {
+		return nil, err
+	}
+	ret = make(map[string]int, len(entries))
+	for k, v := range entries {
+		vInt, err := parseInt(v)
+		if err != nil {
+			return nil, fmt.Errorf("map entry %q: %v", k, err)
+		}
+		ret[k] = vInt
+	}
+	return ret, nil
+}
+
+// joinMap writes entries as a {k:v} map sorted by key, quoting keys and values as needed
+func joinMap(entries map[string]string) string {
+	keys := sortedKeys(entries)
+	for i, k := range keys {
+		keys[i] = quoteElem(k, ",:}") + ":" + quoteElem(entries[k], ",}")
+	}
+	return fmt.Sprintf("{%v}", strings.Join(keys, ","))
+}
+
+func formatIntMap(entries map[string]int) string {
+	texts := make(map[string]string, len(entries))
+	for k, v := range entries {
+		texts[k] = strconv.Itoa(v)
+	}
+	return joinMap(texts)
+}
+
+func sortedKeys(entries map[string]string) []string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
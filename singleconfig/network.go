@@ -0,0 +1,132 @@
+package singleconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HostPort is a validated host:port pair, etc: example.com:443, [::1]:80 or :8080
+type HostPort struct {
+	Host string // host name or IP address, empty for every interface
+	Port uint16
+}
+
+func (h HostPort) String() string {
+	return net.JoinHostPort(h.Host, strconv.FormatUint(uint64(h.Port), 10))
+}
+
+func parseIP(raw string) (net.IP, error) {
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return nil, fmt.Errorf("%q is not an IP address", raw)
+	}
+	return ip, nil
+}
+
+// parseCIDR returns the network of raw, the host bits are dropped, etc: 10.1.2.3/8 is 10.0.0.0/8
+func parseCIDR(raw string) (*net.IPNet, error) {
+	_, ipNet, err := net.ParseCIDR(raw)
+	return ipNet, err
+}
+
+// parseHostPort needs a numeric port, the host may be empty, an IP address or a host name
+func parseHostPort(raw string) (hostPort HostPort, err error) {
+	host, port, err := net.SplitHostPort(raw)
+	if err != nil {
+		return hostPort, err
+	}
+	vPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return hostPort, fmt.Errorf("invalid port %q", port)
+	}
+	if host != "" && net.ParseIP(host) == nil && !isHostName(host) {
+		return hostPort, fmt.Errorf("invalid host %q", host)
+	}
+	return HostPort{Host: host, Port: uint16(vPort)}, nil
+}
+
+// isHostName reports whether every dot separated label of host is 1 to 63
+// letters, digits, '-' or '_' and does not start or end with '-'
+func isHostName(host string) bool {
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseURL only accepts absolute URLs, etc: https://example.com/api
+func parseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", raw)
+	}
+	return u, nil
+}
+
+func parseIPList(raw string) (ret []net.IP, err error) {
+	ret = make([]net.IP, 0)
+	err = eachListElem(raw, func(elem string) error {
+		ip, err := parseIP(elem)
+		ret = append(ret, ip)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseCIDRList(raw string) (ret []*net.IPNet, err error) {
+	ret = make([]*net.IPNet, 0)
+	err = eachListElem(raw, func(elem string) error {
+		ipNet, err := parseCIDR(elem)
+		ret = append(ret, ipNet)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseHostPortList(raw string) (ret []HostPort, err error) {
+	ret = make([]HostPort, 0)
+	err = eachListElem(raw, func(elem string) error {
+		hostPort, err := parseHostPort(elem)
+		ret = append(ret, hostPort)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseURLList(raw string) (ret []*url.URL, err error) {
+	ret = make([]*url.URL, 0)
+	err = eachListElem(raw, func(elem string) error {
+		u, err := parseURL(elem)
+		ret = append(ret, u)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
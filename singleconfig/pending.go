@@ -0,0 +1,64 @@
+package singleconfig
+
+import (
+	"sort"
+)
+
+// Change is a value set by SetValue that is not flushed to the file yet
+type Change struct {
+	File    string
+	Section ConfigType
+	Key     string
+	Old     string // text in the file, empty for a new key
+	New     string // text FlushToConfig writes
+	Added   bool   // the key is not in the file yet
+}
+
+// setRaw sets the text of key in section and records the change, setting a
+// key back to the text in the file drops it
+func (s *SingleConfig) setRaw(section, key, raw string) {
+	if s.changes == nil {
+		s.changes = make(map[string]map[string]*Change)
+	}
+	if s.changes[section] == nil {
+		s.changes[section] = make(map[string]*Change)
+	}
+	change, ok := s.changes[section][key]
+	if !ok {
+		old, had := getSection(ConfigType(section), s.cfg)[key]
+		change = &Change{File: s.filePath, Section: ConfigType(section), Key: key, Old: old, Added: !had}
+	}
+	change.New = raw
+	if !change.Added && change.New == change.Old {
+		delete(s.changes[section], key)
+	} else {
+		s.changes[section][key] = change
+	}
+	s.cfg.SetValue(section, key, raw)
+}
+
+// Dirty reports whether SetValue changed a value since the last flush
+func (s *SingleConfig) Dirty() bool {
+	for _, keys := range s.changes {
+		if len(keys) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns the values changed since the last flush, in section
+// order, the keys of a section sorted
+func (s *SingleConfig) Pending() (changes []Change) {
+	for _, configType := range ConfigTypes {
+		keys := make([]string, 0, len(s.changes[string(configType)]))
+		for key := range s.changes[string(configType)] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			changes = append(changes, *s.changes[string(configType)][key])
+		}
+	}
+	return changes
+}
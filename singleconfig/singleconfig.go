@@ -1,19 +1,28 @@
 package singleconfig
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
 	"reflect"
-	"strconv"
-	"strings"
-
-	util "github.com/UangDesign/multiconfig/utils"
+	"time"
 
 	"github.com/Unknwon/goconfig"
 )
 
+// SingleConfig is one configuration file, it is not safe for concurrent use,
+// MultiConfig serializes every access to its layers
 type SingleConfig struct {
-	filePath         string
-	cfg              *goconfig.ConfigFile
+	filePath string
+	format   fileFormat
+	doc      *document
+	cfg      *goconfig.ConfigFile
+	backups  int // number of .bak files FlushToConfig keeps
+	// values set since the last flush, section -> key -> change
+	changes          map[string]map[string]*Change
 	ConfigString     configString
 	ConfigBool       configBool
 	ConfigInt        configInt
@@ -26,41 +35,76 @@ type SingleConfig struct {
 	ConfigFloat64    configFloat64
 }
 
+// NewSingleConfig loads filePath and returns nil if it can not be loaded,
+// use LoadSingleConfig to find out why
 func NewSingleConfig(filePath string) (config *SingleConfig) {
-	if !util.IsFile(filePath) {
-		config = nil
-	} else {
-		config = &SingleConfig{
-			cfg:              getConfHandler(filePath),
-			filePath:         filePath,
-			ConfigString:     configString{config: make(map[string]string)},
-			ConfigBool:       configBool{config: make(map[string]bool)},
-			ConfigInt:        configInt{config: make(map[string]int)},
-			ConfigUint:       configUint{config: make(map[string]uint)},
-			ConfigInt64:      configInt64{config: make(map[string]int64)},
-			ConfigUint64:     configUint64{config: make(map[string]uint64)},
-			ConfigStringList: configStringList{config: make(map[string][]string)},
-			ConfigIntList:    configIntList{config: make(map[string][]int)},
-			ConfigFloat32:    configFloat32{config: make(map[string]float32)},
-			ConfigFloat64:    configFloat64{config: make(map[string]float64)},
-		}
-	}
+	config, _ = LoadSingleConfig(filePath)
 	return config
 }
 
+// LoadSingleConfig loads filePath, a failure is reported as a *ConfigError.
+// Files ending in .json hold the typed sections as JSON objects, etc:
+// {"sectionInt": {"TEST_INT": 37}}. Files ending in .yaml or .yml may use
+// the typed sections too, any other top level key is placed by its YAML
+// type. Files ending in .toml work the same way with TOML tables and types.
+// Any other file is read as INI
+func LoadSingleConfig(filePath string) (config *SingleConfig, err error) {
+	doc, err := loadConfHandler(filePath)
+	if err != nil {
+		return nil, err
+	}
+	config = &SingleConfig{
+		doc:              doc,
+		cfg:              doc.cfg,
+		filePath:         filePath,
+		format:           formatOf(filePath),
+		ConfigString:     configString{config: make(map[string]string)},
+		ConfigBool:       configBool{config: make(map[string]bool)},
+		ConfigInt:        configInt{config: make(map[string]int)},
+		ConfigUint:       configUint{config: make(map[string]uint)},
+		ConfigInt64:      configInt64{config: make(map[string]int64)},
+		ConfigUint64:     configUint64{config: make(map[string]uint64)},
+		ConfigStringList: configStringList{config: make(map[string][]string)},
+		ConfigIntList:    configIntList{config: make(map[string][]int)},
+		ConfigFloat32:    configFloat32{config: make(map[string]float32)},
+		ConfigFloat64:    configFloat64{config: make(map[string]float64)},
+	}
+	return config, nil
+}
+
 type ConfigType string
 
 const (
-	CFG_STRING     ConfigType = "sectionString"
-	CFG_BOOL       ConfigType = "sectionBool"
-	CFG_INT        ConfigType = "sectionInt"
-	CFG_UINT       ConfigType = "sectionUint"
-	CFG_INT64      ConfigType = "sectionInt64"
-	CFG_UINT64     ConfigType = "sectionUint64"
-	CFG_STRINGLIST ConfigType = "sectionStringList" // etc: [one,two,three]
-	CFG_INTLIST    ConfigType = "sectionIntList"    // etc: [1,2,3]
-	CFG_FLOAT32    ConfigType = "sectionFloat32"
-	CFG_FLOAT64    ConfigType = "sectionFloat64"
+	CFG_STRING         ConfigType = "sectionString"
+	CFG_BOOL           ConfigType = "sectionBool"
+	CFG_INT            ConfigType = "sectionInt"
+	CFG_UINT           ConfigType = "sectionUint"
+	CFG_INT64          ConfigType = "sectionInt64"
+	CFG_UINT64         ConfigType = "sectionUint64"
+	CFG_STRINGLIST     ConfigType = "sectionStringList" // etc: [one,two,three]
+	CFG_INTLIST        ConfigType = "sectionIntList"    // etc: [1,2,3]
+	CFG_FLOAT32        ConfigType = "sectionFloat32"
+	CFG_FLOAT64        ConfigType = "sectionFloat64"
+	CFG_DURATION       ConfigType = "sectionDuration"     // etc: 1h30m
+	CFG_TIME           ConfigType = "sectionTime"         // etc: 2006-01-02T15:04:05Z07:00
+	CFG_DURATIONLIST   ConfigType = "sectionDurationList" // etc: [1s,500ms]
+	CFG_BYTESIZE       ConfigType = "sectionByteSize"     // etc: 512KB, 10MiB
+	CFG_IP             ConfigType = "sectionIP"           // etc: 10.0.0.1 or ::1
+	CFG_CIDR           ConfigType = "sectionCIDR"         // etc: 10.0.0.0/8
+	CFG_HOSTPORT       ConfigType = "sectionHostPort"     // etc: example.com:443
+	CFG_URL            ConfigType = "sectionURL"          // etc: https://example.com/api
+	CFG_IPLIST         ConfigType = "sectionIPList"
+	CFG_CIDRLIST       ConfigType = "sectionCIDRList"
+	CFG_HOSTPORTLIST   ConfigType = "sectionHostPortList"
+	CFG_URLLIST        ConfigType = "sectionURLList"
+	CFG_STRINGMAP      ConfigType = "sectionStringMap"      // etc: {region:us-east, tier:gold}
+	CFG_INTMAP         ConfigType = "sectionIntMap"         // etc: {tenant1:100, tenant2:250}
+	CFG_STRINGLISTLIST ConfigType = "sectionStringListList" // etc: [[a,b],[c]]
+	CFG_INTLISTLIST    ConfigType = "sectionIntListList"    // etc: [[1,2],[3]]
+	CFG_INT64LIST      ConfigType = "sectionInt64List"
+	CFG_UINT64LIST     ConfigType = "sectionUint64List"
+	CFG_FLOAT64LIST    ConfigType = "sectionFloat64List"
+	CFG_BOOLLIST       ConfigType = "sectionBoolList"
 )
 
 type configString struct {
@@ -114,7 +158,7 @@ func (c *configString) ParseConfig(cfg *goconfig.ConfigFile) map[string]string {
 // parseConfig is used to parse the bool configuration
 func (c *configBool) ParseConfig(cfg *goconfig.ConfigFile) map[string]bool {
 	for k, v := range getSection(CFG_BOOL, cfg) {
-		if vb, err := strconv.ParseBool(v); err == nil {
+		if vb, err := parseBool(v); err == nil {
 			c.config[k] = vb
 		}
 	}
@@ -124,7 +168,7 @@ func (c *configBool) ParseConfig(cfg *goconfig.ConfigFile) map[string]bool {
 // parseConfig is used to parse the int configuration
 func (c *configInt) ParseConfig(cfg *goconfig.ConfigFile) map[string]int {
 	for k, v := range getSection(CFG_INT, cfg) {
-		if vInt, err := strconv.Atoi(v); err == nil {
+		if vInt, err := parseInt(v); err == nil {
 			c.config[k] = vInt
 		}
 	}
@@ -134,8 +178,8 @@ func (c *configInt) ParseConfig(cfg *goconfig.ConfigFile) map[string]int {
 // parseConfig is used to parse the uint configuration
 func (c *configUint) ParseConfig(cfg *goconfig.ConfigFile) map[string]uint {
 	for k, v := range getSection(CFG_UINT, cfg) {
-		if vUint, err := strconv.ParseUint(v, 10, 0); err == nil {
-			c.config[k] = uint(vUint)
+		if vUint, err := parseUint(v); err == nil {
+			c.config[k] = vUint
 		}
 	}
 	return c.config
@@ -144,7 +188,7 @@ func (c *configUint) ParseConfig(cfg *goconfig.ConfigFile) map[string]uint {
 // parseConfig is used to parse the int64 configuration
 func (c *configInt64) ParseConfig(cfg *goconfig.ConfigFile) map[string]int64 {
 	for k, v := range getSection(CFG_INT64, cfg) {
-		if vInt64, err := strconv.ParseInt(v, 10, 0); err == nil {
+		if vInt64, err := parseInt64(v); err == nil {
 			c.config[k] = vInt64
 		}
 	}
@@ -154,7 +198,7 @@ func (c *configInt64) ParseConfig(cfg *goconfig.ConfigFile) map[string]int64 {
 // parseConfig is used to parse the uint64 configuration
 func (c *configUint64) ParseConfig(cfg *goconfig.ConfigFile) map[string]uint64 {
 	for k, v := range getSection(CFG_UINT64, cfg) {
-		if vUint, err := strconv.ParseUint(v, 10, 0); err == nil {
+		if vUint, err := parseUint64(v); err == nil {
 			c.config[k] = vUint
 		}
 	}
@@ -169,13 +213,8 @@ func getSection(configType ConfigType, cfg *goconfig.ConfigFile) map[string]stri
 // parseConfig is used to parse the []string configuration
 func (c *configStringList) ParseConfig(cfg *goconfig.ConfigFile) map[string][]string {
 	for k, v := range getSection(CFG_STRINGLIST, cfg) {
-		if isList(v) {
-			if trimBracket(&v); v != "" {
-				vList := strings.Split(v, ",")
-				if len(vList) > 0 {
-					c.config[k] = trimSpace(vList)
-				}
-			}
+		if vList, err := parseLenient(CFG_STRINGLIST, v); err == nil {
+			c.config[k] = vList.([]string)
 		}
 	}
 	return c.config
@@ -184,13 +223,8 @@ func (c *configStringList) ParseConfig(cfg *goconfig.ConfigFile) map[string][]st
 // parseConfig is used to parse the []int configuration
 func (c *configIntList) ParseConfig(cfg *goconfig.ConfigFile) map[string][]int {
 	for k, v := range getSection(CFG_INTLIST, cfg) {
-		if isList(v) {
-			if trimBracket(&v); v != "" {
-				vList := strings.Split(v, ",")
-				if len(vList) > 0 {
-					c.config[k] = trimSpaceToIntList(vList)
-				}
-			}
+		if vList, err := parseLenient(CFG_INTLIST, v); err == nil {
+			c.config[k] = vList.([]int)
 		}
 	}
 	return c.config
@@ -199,8 +233,8 @@ func (c *configIntList) ParseConfig(cfg *goconfig.ConfigFile) map[string][]int {
 // parseConfig is used to parse the float32 configuration
 func (c *configFloat32) ParseConfig(cfg *goconfig.ConfigFile) map[string]float32 {
 	for k, v := range getSection(CFG_FLOAT32, cfg) {
-		if vFloat32, err := strconv.ParseFloat(v, 32); err == nil {
-			c.config[k] = float32(vFloat32)
+		if vFloat32, err := parseFloat32(v); err == nil {
+			c.config[k] = vFloat32
 		}
 	}
 	return c.config
@@ -209,135 +243,237 @@ func (c *configFloat32) ParseConfig(cfg *goconfig.ConfigFile) map[string]float32
 // parseConfig is used to parse the float64 configuration
 func (c *configFloat64) ParseConfig(cfg *goconfig.ConfigFile) map[string]float64 {
 	for k, v := range getSection(CFG_FLOAT64, cfg) {
-		if vFloat64, err := strconv.ParseFloat(v, 64); err == nil {
+		if vFloat64, err := parseFloat64(v); err == nil {
 			c.config[k] = vFloat64
 		}
 	}
 	return c.config
 }
 
-// isList determine if it is a list
-func isList(list string) (is bool) {
-	if strings.HasPrefix(list, "[") && strings.HasSuffix(list, "]") {
-		is = true
-	} else {
-		is = false
-	}
-	return is
+func (s *SingleConfig) GetConfigFile() *goconfig.ConfigFile {
+	return s.cfg
 }
 
-func trimBracket(list *string) {
-	*list = strings.TrimRight(strings.TrimLeft(*list, "["), "]")
+func (s *SingleConfig) GetConfPath() (filePath string) {
+	return s.filePath
 }
 
-func trimSpaceToIntList(stringList []string) (ret []int) {
-	ret = make([]int, 0)
-	for _, v := range stringList {
-		if v = strings.Trim(v, " "); v != "" {
-			if vInt, err := strconv.Atoi(v); err == nil {
-				ret = append(ret, vInt)
-			}
+func (s *SingleConfig) HasKey(key string) (has bool) {
+	for _, configType := range ConfigTypes {
+		if _, has = getSection(configType, s.cfg)[key]; has {
+			break
 		}
 	}
-	return ret
+	return has
 }
 
-func trimSpace(stringList []string) (ret []string) {
-	ret = make([]string, 0)
-	for _, v := range stringList {
-		if v = strings.Trim(v, " "); v != "" {
-			ret = append(ret, v)
+// Values returns the parsed values of the section configType,
+// values that can not be parsed are skipped like ParseConfig does
+func (s *SingleConfig) Values(configType ConfigType) map[string]interface{} {
+	values := make(map[string]interface{})
+	for k, v := range getSection(configType, s.cfg) {
+		if value, err := parseLenient(configType, v); err == nil {
+			values[k] = value
 		}
 	}
-	return ret
+	return values
 }
 
-func (s *SingleConfig) GetConfigFile() *goconfig.ConfigFile {
-	return s.cfg
+// Lookup returns the text of key in the section configType and the line of
+// the file it is defined on, 0 for keys only set by SetValue. ok is false
+// when the key is not in the section or its value is skipped by Values
+func (s *SingleConfig) Lookup(configType ConfigType, key string) (raw string, line int, ok bool) {
+	if raw, line, ok = s.Definition(configType, key); !ok {
+		return "", 0, false
+	}
+	if _, err := parseLenient(configType, raw); err != nil {
+		return "", 0, false
+	}
+	return raw, line, true
 }
 
-func (s *SingleConfig) GetConfPath() (filePath string) {
-	return s.filePath
+// Definition is Lookup for values that can not be parsed too
+func (s *SingleConfig) Definition(configType ConfigType, key string) (raw string, line int, ok bool) {
+	raw, ok = getSection(configType, s.cfg)[key]
+	if !ok {
+		return "", 0, false
+	}
+	return raw, s.doc.line(string(configType), key), true
 }
 
-func (s *SingleConfig) HasKey(key string) (has bool) {
-	if _, ok := s.ConfigBool.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigString.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigInt.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigInt64.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigUint.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigInt64.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigFloat32.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigFloat64.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigIntList.config[key]; ok {
-		has = true
-	} else if _, ok := s.ConfigStringList.config[key]; ok {
-		has = true
-	}
-	return has
+// Keys returns the keys of the section configType in file order, keys with
+// values that can not be parsed included
+func (s *SingleConfig) Keys(configType ConfigType) []string {
+	return s.cfg.GetKeyList(string(configType))
 }
 
+// Sections returns every section key is defined in
+func (s *SingleConfig) Sections(key string) (sections []ConfigType) {
+	for _, configType := range ConfigTypes {
+		if _, has := getSection(configType, s.cfg)[key]; has {
+			sections = append(sections, configType)
+		}
+	}
+	return sections
+}
+
+// valueTypes maps the lists, maps and other values SetValue does not tell
+// apart by type name to their section
+var valueTypes = map[reflect.Type]ConfigType{
+	reflect.TypeOf(time.Duration(0)):    CFG_DURATION,
+	reflect.TypeOf(time.Time{}):         CFG_TIME,
+	reflect.TypeOf(ByteSize(0)):         CFG_BYTESIZE,
+	reflect.TypeOf(net.IP{}):            CFG_IP,
+	reflect.TypeOf(HostPort{}):          CFG_HOSTPORT,
+	reflect.TypeOf([]string{}):          CFG_STRINGLIST,
+	reflect.TypeOf([]int{}):             CFG_INTLIST,
+	reflect.TypeOf([][]string{}):        CFG_STRINGLISTLIST,
+	reflect.TypeOf([][]int{}):           CFG_INTLISTLIST,
+	reflect.TypeOf([]int64{}):           CFG_INT64LIST,
+	reflect.TypeOf([]uint64{}):          CFG_UINT64LIST,
+	reflect.TypeOf([]float64{}):         CFG_FLOAT64LIST,
+	reflect.TypeOf([]bool{}):            CFG_BOOLLIST,
+	reflect.TypeOf([]time.Duration{}):   CFG_DURATIONLIST,
+	reflect.TypeOf(map[string]string{}): CFG_STRINGMAP,
+	reflect.TypeOf(map[string]int{}):    CFG_INTMAP,
+	reflect.TypeOf(&net.IPNet{}):        CFG_CIDR,
+	reflect.TypeOf(&url.URL{}):          CFG_URL,
+	reflect.TypeOf([]net.IP{}):          CFG_IPLIST,
+	reflect.TypeOf([]*net.IPNet{}):      CFG_CIDRLIST,
+	reflect.TypeOf([]HostPort{}):        CFG_HOSTPORTLIST,
+	reflect.TypeOf([]*url.URL{}):        CFG_URLLIST,
+}
+
+// SetValue sets key in the section of the type of value, valueType names
+// the type. A type no section holds or a value that can not be written is
+// rejected
 func (s *SingleConfig) SetValue(key string, value interface{}) (valueType string, err error) {
+	if value == nil {
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
 	valueType = reflect.TypeOf(value).Name()
 	switch valueType {
 	case "string":
-		s.cfg.SetValue(string(CFG_STRING), key, value.(string))
+		s.setRaw(string(CFG_STRING), key, value.(string))
 		s.ConfigString.ParseConfig(s.cfg)
 	case "bool":
-		s.cfg.SetValue(string(CFG_BOOL), key, fmt.Sprintf("%v", value.(bool)))
+		s.setRaw(string(CFG_BOOL), key, fmt.Sprintf("%v", value.(bool)))
 		s.ConfigBool.ParseConfig(s.cfg)
 	case "int":
-		s.cfg.SetValue(string(CFG_INT), key, fmt.Sprintf("%v", value.(int)))
+		s.setRaw(string(CFG_INT), key, fmt.Sprintf("%v", value.(int)))
 		s.ConfigInt.ParseConfig(s.cfg)
 	case "int64":
-		s.cfg.SetValue(string(CFG_INT64), key, fmt.Sprintf("%v", value.(int64)))
+		s.setRaw(string(CFG_INT64), key, fmt.Sprintf("%v", value.(int64)))
 		s.ConfigInt64.ParseConfig(s.cfg)
 	case "uint":
-		s.cfg.SetValue(string(CFG_UINT), key, fmt.Sprintf("%v", value.(uint)))
+		s.setRaw(string(CFG_UINT), key, fmt.Sprintf("%v", value.(uint)))
 		s.ConfigUint.ParseConfig(s.cfg)
 	case "uint64":
-		s.cfg.SetValue(string(CFG_UINT64), key, fmt.Sprintf("%v", value.(uint64)))
+		s.setRaw(string(CFG_UINT64), key, fmt.Sprintf("%v", value.(uint64)))
 		s.ConfigUint64.ParseConfig(s.cfg)
 	case "float32":
-		s.cfg.SetValue(string(CFG_FLOAT32), key, fmt.Sprintf("%v", value.(float32)))
+		s.setRaw(string(CFG_FLOAT32), key, fmt.Sprintf("%v", value.(float32)))
 		s.ConfigFloat32.ParseConfig(s.cfg)
 	case "float64":
-		s.cfg.SetValue(string(CFG_FLOAT64), key, fmt.Sprintf("%v", value.(float64)))
+		s.setRaw(string(CFG_FLOAT64), key, fmt.Sprintf("%v", value.(float64)))
 		s.ConfigFloat64.ParseConfig(s.cfg)
 	default:
-		if v, ok := value.([]string); ok {
-			s.cfg.SetValue(string(CFG_STRING), key, fmt.Sprintf("[%v]", strings.Join(v, ",")))
+		configType, ok := valueTypes[reflect.TypeOf(value)]
+		if !ok {
+			return valueType, fmt.Errorf("unsupported type %T", value)
+		}
+		raw, err := FormatValue(configType, value)
+		if err != nil {
+			return valueType, err
+		}
+		s.setRaw(string(configType), key, raw)
+		switch configType {
+		case CFG_STRINGLIST:
 			s.ConfigStringList.ParseConfig(s.cfg)
-			valueType = "[]string"
-		} else if v, ok := value.([]int); ok {
-			intToString := []string{}
-			for _, intV := range v {
-				intToString = append(intToString, strconv.Itoa(intV))
-			}
-			s.cfg.SetValue(string(CFG_INTLIST), key, fmt.Sprintf("[%v]", strings.Join(intToString, ",")))
+		case CFG_INTLIST:
 			s.ConfigIntList.ParseConfig(s.cfg)
-			valueType = "[]int"
 		}
+		valueType = configType.GoType()
 	}
 	return valueType, err
 }
 
+// Check parses every value of every typed section and reports those that are invalid,
+// the ParseConfig methods silently skip them
+func (s *SingleConfig) Check() (errs ValueErrors) {
+	for _, configType := range ConfigTypes {
+		section := getSection(configType, s.cfg)
+		for _, key := range s.cfg.GetKeyList(string(configType)) {
+			raw, ok := section[key]
+			if !ok {
+				continue
+			}
+			if _, err := ParseValue(configType, raw); err != nil {
+				errs = append(errs, &ValueError{
+					File:    s.filePath,
+					Section: configType,
+					Key:     key,
+					Raw:     raw,
+					Type:    configType.GoType(),
+					Err:     err,
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// SetBackups makes FlushToConfig keep the last n versions of the file as
+// .bak, .bak.1 ... .bak.<n-1>, 0 keeps none
+func (s *SingleConfig) SetBackups(n int) {
+	s.backups = n
+}
+
+// FlushToConfig writes the configuration back to its file. An INI file
+// keeps its comments and layout, only the lines of changed keys are
+// rewritten and new keys are added to the end of their section. The file is
+// replaced atomically, a crash leaves the old or the new content. A file
+// without changes is not written
 func (s *SingleConfig) FlushToConfig() (err error) {
-	return goconfig.SaveConfigFile(s.cfg, s.filePath)
+	if !s.Dirty() {
+		return nil
+	}
+	flush, err := s.StageFlush()
+	if err != nil {
+		return err
+	}
+	if err = flush.Commit(); err != nil {
+		flush.Abort()
+		return err
+	}
+	return nil
 }
 
-func getConfHandler(filename string) (cfg *goconfig.ConfigFile) {
-	cfg, err := goconfig.LoadConfigFile(filename)
+func loadConfHandler(filename string) (doc *document, err error) {
+	info, err := os.Stat(filename)
 	if err != nil {
-		panic(fmt.Sprintf("getcfg failed err is:%v", err))
+		return nil, fileError(filename, err)
+	}
+	if info.IsDir() {
+		return nil, &ConfigError{File: filename, Kind: ERR_IS_DIR}
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fileError(filename, err)
+	}
+	if doc, err = decodeConfig(formatOf(filename), filename, data); err != nil {
+		return nil, err
+	}
+	doc.sum = sha256.Sum256(data)
+	return doc, nil
+}
+
+func fileError(filename string, err error) *ConfigError {
+	configErr := &ConfigError{File: filename, Kind: ERR_READ, Err: err}
+	if os.IsNotExist(err) {
+		configErr.Kind = ERR_NOT_EXIST
+	} else if os.IsPermission(err) {
+		configErr.Kind = ERR_PERMISSION
 	}
-	return cfg
+	return configErr
 }
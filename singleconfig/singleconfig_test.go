@@ -0,0 +1,50 @@
+package singleconfig
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetValueUnsupported(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	data := "[sectionString]\nK = a\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type port int
+	for _, value := range []interface{}{
+		nil,
+		int32(1),
+		port(80),
+		[]uint{1},
+		map[string]bool{"a": true},
+		net.IP(nil),
+		net.IP{1, 2, 3},
+		(*net.IPNet)(nil),
+		(*url.URL)(nil),
+		[]*url.URL{nil},
+		[]net.IP{nil},
+	} {
+		if _, err := config.SetValue("K", value); err == nil {
+			t.Errorf("SetValue(%T %v) is accepted", value, value)
+		}
+	}
+	if config.Dirty() {
+		t.Errorf("a rejected value is pending: %v", config.Pending())
+	}
+
+	if valueType, err := config.SetValue("D", 90*time.Second); err != nil || valueType != "time.Duration" {
+		t.Errorf("SetValue(time.Duration) = %s, %v", valueType, err)
+	}
+	if raw, _, _ := config.Lookup(CFG_DURATION, "D"); raw != "1m30s" {
+		t.Errorf("D = %q", raw)
+	}
+}
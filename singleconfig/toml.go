@@ -0,0 +1,482 @@
+package singleconfig
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	util "github.com/UangDesign/multiconfig/utils"
+
+	"github.com/BurntSushi/toml"
+)
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// decodeTOML reads a TOML document. Tables named like a typed section hold
+// that section, every other key is placed by its TOML type, keys of other
+// tables are flattened into dotted keys, etc: server.port
+func decodeTOML(filePath string, data []byte) (doc *document, err error) {
+	values := make(map[string]interface{})
+	meta, err := toml.Decode(string(data), &values)
+	if err != nil {
+		configErr := &ConfigError{File: filePath, Kind: ERR_SYNTAX, Err: err}
+		if parseErr, ok := err.(toml.ParseError); ok {
+			configErr.Line = parseErr.Position.Line
+			configErr.Content = lineContent(data, configErr.Line)
+		}
+		return nil, configErr
+	}
+	doc = newDocument()
+	doc.toml = newTOMLFile(data)
+	lines := make(map[string]int, len(doc.toml.spans))
+	for path, span := range doc.toml.spans {
+		lines[path] = span.first + 1
+	}
+	for _, key := range meta.Keys() {
+		value := tomlLookup(values, key)
+		if len(key) > 2 && ConfigType(key[0]).IsMap() {
+			// an entry of a map, read with the whole table below
+			continue
+		}
+		if table, ok := value.(map[string]interface{}); ok && len(key) == 2 && ConfigType(key[0]).IsMap() {
+			raw, err := tomlMap(table)
+			if err != nil {
+				return nil, &ConfigError{File: filePath, Kind: ERR_SYNTAX, Line: lines[key.String()], Content: lineContent(data, lines[key.String()]), Err: fmt.Errorf("%s: %v", key, err)}
+			}
+			doc.cfg.SetValue(key[0], key[1], raw)
+			doc.setLine(key[0], key[1], lines[key.String()])
+			doc.setPath(key[0], key[1], key)
+			doc.toml.values[key.String()] = raw
+			continue
+		}
+		if _, ok := value.(map[string]interface{}); ok {
+			if len(key) == 1 && isTypedSection(key[0]) {
+				// make the section exist even though it does not have any key
+				doc.cfg.SetValue(key[0], " ", " ")
+			}
+			continue
+		}
+		configType, raw, err := tomlValue(value)
+		if err != nil {
+			return nil, &ConfigError{File: filePath, Kind: ERR_SYNTAX, Err: fmt.Errorf("%s: %v", key, err)}
+		}
+		doc.toml.values[key.String()] = raw
+		if len(key) == 2 && isTypedSection(key[0]) {
+			doc.cfg.SetValue(key[0], key[1], raw)
+			doc.setLine(key[0], key[1], lines[key.String()])
+			doc.setPath(key[0], key[1], key)
+			continue
+		}
+		doc.cfg.SetValue(string(configType), strings.Join(key, "."), raw)
+		doc.setLine(string(configType), strings.Join(key, "."), lines[key.String()])
+		doc.setPath(string(configType), strings.Join(key, "."), key)
+	}
+	return doc, nil
+}
+
+// tomlFile is the line model of a TOML file. A flush only replaces the
+// text of the values that changed and adds new keys after the last key of
+// their table, comments and the layout of the file are kept
+type tomlFile struct {
+	lines    []string // physical lines without their line break
+	eol      string   // "\r" if the file uses CRLF line breaks
+	trailing bool     // whether the file ends with a line break
+	// value of every key by its full dotted path, keys of arrays of tables
+	// are not recorded
+	spans map[string]tomlSpan
+	// values as read by path, a key whose value is unchanged keeps its text
+	values map[string]string
+	// index of the line after which a new key of the table is inserted, -1
+	// for the top level of a file without top level keys
+	ends map[string]int
+}
+
+// tomlSpan holds the 0-based first and last physical line of a value, the
+// value runs from lines[first][start:] to lines[last][:end], a comment after
+// it is not part of it
+type tomlSpan struct {
+	first, last int
+	start, end  int
+}
+
+// newTOMLFile builds the line model of data, the decoder does not report
+// where keys are defined
+func newTOMLFile(data []byte) *tomlFile {
+	file := &tomlFile{
+		lines:  strings.Split(string(data), "\n"),
+		spans:  make(map[string]tomlSpan),
+		values: make(map[string]string),
+		ends:   map[string]int{"": -1},
+	}
+	if n := len(file.lines); file.lines[n-1] == "" {
+		file.lines, file.trailing = file.lines[:n-1], true
+	}
+	if len(file.lines) > 0 && strings.HasSuffix(file.lines[0], "\r") {
+		file.eol = "\r"
+	}
+	var table []string
+	inArray := false
+	for i := 0; i < len(file.lines); i++ {
+		text := strings.TrimSpace(file.lines[i])
+		switch {
+		case text == "" || text[0] == '#':
+			continue
+		case strings.HasPrefix(text, "[["):
+			inArray = true
+			continue
+		case text[0] == '[':
+			if end := strings.Index(text, "]"); end > 0 {
+				table, inArray = tomlPath(text[1:end]), false
+				file.ends[toml.Key(table).String()] = i
+			}
+			continue
+		}
+		sep := strings.Index(file.lines[i], "=")
+		if sep < 0 {
+			continue
+		}
+		span, ok := tomlValueSpan(file.lines, i, sep+1)
+		if !ok || inArray {
+			continue
+		}
+		path := toml.Key(append(append([]string{}, table...), tomlPath(file.lines[i][:sep])...)).String()
+		if _, ok := file.spans[path]; !ok {
+			file.spans[path] = span
+		}
+		file.ends[toml.Key(table).String()] = span.last
+		// a line of a multi-line value may look like a table
+		i = span.last
+	}
+	return file
+}
+
+// tomlValueSpan finds the end of the value starting at lines[first][start:]
+// by decoding ever longer parts of the file
+func tomlValueSpan(lines []string, first, start int) (span tomlSpan, ok bool) {
+	for start < len(lines[first]) && (lines[first][start] == ' ' || lines[first][start] == '\t') {
+		start++
+	}
+	span = tomlSpan{first: first, start: start}
+	text := lines[first][start:]
+	for span.last = first; span.last < len(lines); span.last++ {
+		if span.last > first {
+			text += "\n" + lines[span.last]
+		}
+		if !tomlValid(text) {
+			continue
+		}
+		// the value ends at the first # that leaves a valid value before it
+		line, from := lines[span.last], 0
+		if span.last == first {
+			from = start
+		}
+		head := text[:len(text)-len(line)+from]
+		span.end = len(strings.TrimRight(line, " \t\r"))
+		for i := from; i < len(line); i++ {
+			if line[i] == '#' && tomlValid(head+line[from:i]) {
+				span.end = len(strings.TrimRight(line[:i], " \t"))
+				break
+			}
+		}
+		return span, true
+	}
+	return span, false
+}
+
+// tomlValid reports whether text is a complete TOML value
+func tomlValid(text string) bool {
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	var values map[string]interface{}
+	_, err := toml.Decode("v = "+text, &values)
+	return err == nil
+}
+
+// tomlPath splits a dotted TOML key into its unquoted parts
+func tomlPath(key string) (path []string) {
+	for _, part := range strings.Split(key, ".") {
+		part = strings.TrimSpace(part)
+		if unquoted, err := strconv.Unquote(part); err == nil {
+			part = unquoted
+		} else {
+			part = strings.Trim(part, "'")
+		}
+		path = append(path, part)
+	}
+	return path
+}
+
+func tomlLookup(values map[string]interface{}, key toml.Key) (value interface{}) {
+	value = values
+	for _, part := range key {
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = table[part]
+	}
+	return value
+}
+
+// tomlValue returns the section a TOML value belongs in and its INI text
+func tomlValue(value interface{}) (configType ConfigType, raw string, err error) {
+	switch v := value.(type) {
+	case []interface{}:
+		seq := &sequence{}
+		for _, elem := range v {
+			elemType, elemRaw, err := tomlValue(elem)
+			if err != nil {
+				return "", "", err
+			}
+			seq.add(elemType, elemRaw)
+		}
+		return seq.result()
+	}
+	return tomlScalar(value)
+}
+
+// tomlMap converts a table of scalars to the INI text of a map
+func tomlMap(table map[string]interface{}) (raw string, err error) {
+	entries := make(map[string]string, len(table))
+	for k, v := range table {
+		_, text, err := tomlScalar(v)
+		if err != nil {
+			return "", err
+		}
+		entries[k] = text
+	}
+	return joinMap(entries), nil
+}
+
+func tomlScalar(value interface{}) (configType ConfigType, raw string, err error) {
+	switch v := value.(type) {
+	case string:
+		return CFG_STRING, v, nil
+	case bool:
+		return CFG_BOOL, strconv.FormatBool(v), nil
+	case int64:
+		return CFG_INT, strconv.FormatInt(v, 10), nil
+	case float64:
+		return CFG_FLOAT64, formatNativeFloat(v, 64), nil
+	case time.Time:
+		return CFG_TIME, v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		// local dates and times
+		return CFG_STRING, v.String(), nil
+	}
+	return "", "", fmt.Errorf("%T values are not supported", value)
+}
+
+// encodeTOML writes the values into the lines of the file: a changed value
+// replaces the text of the old one and a new key goes after the last key of
+// its table. A key keeps the place it was read from, a new key of a native
+// type is written as a plain TOML value and any other new key into its typed
+// table. A value that can not be written this way fails the flush instead of
+// rewriting the file
+func encodeTOML(doc *document) (data []byte, err error) {
+	file := doc.toml
+	if file == nil {
+		file = newTOMLFile(nil)
+	}
+	// new text of the changed values by their first line
+	replaced := make(map[int]string)
+	spans := make(map[int]tomlSpan)
+	inserts := make(map[int][]string)
+	tables := make([]string, 0)
+	added := make(map[string][]string)
+	for _, section := range doc.cfg.GetSectionList() {
+		configType := ConfigType(section)
+		values, _ := doc.cfg.GetSection(section)
+		for _, key := range doc.cfg.GetKeyList(section) {
+			raw, ok := values[key]
+			if !ok {
+				continue
+			}
+			if path := doc.paths[section][key]; path != nil {
+				name := toml.Key(path).String()
+				if old, ok := file.values[name]; ok && sameValue(configType, old, raw) {
+					continue
+				}
+				span, ok := file.spans[name]
+				if !ok {
+					return nil, fmt.Errorf("%s: only a value written as key = value can be changed in a TOML file", name)
+				}
+				lines := file.lines
+				replaced[span.first] = lines[span.first][:span.start] + tomlText(configType, raw) + lines[span.last][span.end:]
+				spans[span.first] = span
+				continue
+			}
+			table, name := file.newKey(configType, section, key)
+			line := name + " = " + tomlText(configType, raw) + file.eol
+			if end, ok := file.ends[table]; ok {
+				inserts[end] = append(inserts[end], line)
+				continue
+			}
+			if _, ok := added[table]; !ok {
+				tables = append(tables, table)
+			}
+			added[table] = append(added[table], line)
+		}
+	}
+	out := append(make([]string, 0, len(file.lines)), inserts[-1]...)
+	for i := 0; i < len(file.lines); i++ {
+		if line, ok := replaced[i]; ok {
+			out = append(out, line)
+			i = spans[i].last
+		} else {
+			out = append(out, file.lines[i])
+		}
+		out = append(out, inserts[i]...)
+	}
+	for _, table := range tables {
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, file.eol)
+		}
+		out = append(out, "["+table+"]"+file.eol)
+		out = append(out, added[table]...)
+	}
+	text := strings.Join(out, "\n")
+	if file.trailing && len(out) > 0 {
+		text += "\n"
+	}
+	if err = checkTOML(doc, []byte(text)); err != nil {
+		return nil, err
+	}
+	return []byte(text), nil
+}
+
+// newKey returns the table a new key goes into and the key as written in
+// it. A native key is put into the deepest table of its dotted path that
+// the file has, the rest of the path stays dotted
+func (file *tomlFile) newKey(configType ConfigType, section, key string) (table, name string) {
+	if !isNative(configType) {
+		return toml.Key{section}.String(), tomlKey(key)
+	}
+	parts := strings.Split(key, ".")
+	for n := len(parts) - 1; n > 0; n-- {
+		if _, ok := file.ends[toml.Key(parts[:n]).String()]; ok {
+			return toml.Key(parts[:n]).String(), toml.Key(parts[n:]).String()
+		}
+	}
+	return "", toml.Key(parts).String()
+}
+
+// checkTOML reads data back and makes sure it holds every value of doc
+func checkTOML(doc *document, data []byte) error {
+	written, err := decodeTOML("", data)
+	if err != nil {
+		return fmt.Errorf("the flushed TOML file would not be valid: %v", err)
+	}
+	for _, section := range doc.cfg.GetSectionList() {
+		values, _ := doc.cfg.GetSection(section)
+		readBack, _ := written.cfg.GetSection(section)
+		for key, raw := range values {
+			if read, ok := readBack[key]; !ok || !sameValue(ConfigType(section), read, raw) {
+				return fmt.Errorf("[%s] %s: the value can not be written to a TOML file so it reads back the same", section, key)
+			}
+		}
+	}
+	return nil
+}
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func tomlString(s string) string {
+	// JSON escapes are valid TOML basic string escapes
+	quoted, _ := util.GetJsonIterator().Marshal(s)
+	return string(quoted)
+}
+
+// tomlText converts the INI text of a value of the section to a TOML value
+func tomlText(configType ConfigType, raw string) string {
+	value, err := ParseValue(configType, raw)
+	if err != nil {
+		return tomlString(raw)
+	}
+	switch v := value.(type) {
+	case bool, int, int64, uint:
+		return fmt.Sprint(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			// beyond the range of TOML integers
+			return tomlString(raw)
+		}
+		return fmt.Sprint(v)
+	case float32:
+		return tomlFloat(float64(v), 32)
+	case float64:
+		return tomlFloat(v, 64)
+	case []string:
+		elems := make([]string, 0, len(v))
+		for _, elem := range v {
+			elems = append(elems, tomlString(elem))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case []int:
+		elems := make([]string, 0, len(v))
+		for _, elem := range v {
+			elems = append(elems, strconv.Itoa(elem))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case [][]string:
+		lists := make([]string, 0, len(v))
+		for _, list := range v {
+			lists = append(lists, tomlText(CFG_STRINGLIST, joinList(list)))
+		}
+		return "[" + strings.Join(lists, ", ") + "]"
+	case [][]int:
+		lists := make([]string, 0, len(v))
+		for _, list := range v {
+			lists = append(lists, tomlText(CFG_INTLIST, formatList(list, strconv.Itoa)))
+		}
+		return "[" + strings.Join(lists, ", ") + "]"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]string:
+		entries := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			entries = append(entries, tomlKey(k)+" = "+tomlString(v[k]))
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case map[string]int:
+		entries := make([]string, 0, len(v))
+		for k, entry := range v {
+			entries = append(entries, tomlKey(k)+" = "+strconv.Itoa(entry))
+		}
+		sort.Strings(entries)
+		return "{" + strings.Join(entries, ", ") + "}"
+	}
+	if elems, err := splitList(raw); err == nil && configType.IsList() {
+		for i, elem := range elems {
+			if elemType, ok := listElemTypes[configType]; ok {
+				elems[i] = tomlText(elemType, elem)
+			} else {
+				elems[i] = tomlString(elem)
+			}
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	}
+	return tomlString(raw)
+}
+
+func tomlFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	return formatNativeFloat(f, bitSize)
+}
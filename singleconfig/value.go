@@ -0,0 +1,545 @@
+package singleconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigTypes lists every typed section in the order they are looked up
+var ConfigTypes = []ConfigType{
+	CFG_STRING,
+	CFG_BOOL,
+	CFG_INT,
+	CFG_INT64,
+	CFG_UINT,
+	CFG_UINT64,
+	CFG_FLOAT32,
+	CFG_FLOAT64,
+	CFG_STRINGLIST,
+	CFG_INTLIST,
+	CFG_DURATION,
+	CFG_TIME,
+	CFG_DURATIONLIST,
+	CFG_BYTESIZE,
+	CFG_IP,
+	CFG_CIDR,
+	CFG_HOSTPORT,
+	CFG_URL,
+	CFG_IPLIST,
+	CFG_CIDRLIST,
+	CFG_HOSTPORTLIST,
+	CFG_URLLIST,
+	CFG_STRINGMAP,
+	CFG_INTMAP,
+	CFG_STRINGLISTLIST,
+	CFG_INTLISTLIST,
+	CFG_INT64LIST,
+	CFG_UINT64LIST,
+	CFG_FLOAT64LIST,
+	CFG_BOOLLIST,
+}
+
+// GoType returns the name of the Go type values of the section are parsed into
+func (t ConfigType) GoType() string {
+	switch t {
+	case CFG_STRING:
+		return "string"
+	case CFG_BOOL:
+		return "bool"
+	case CFG_INT:
+		return "int"
+	case CFG_INT64:
+		return "int64"
+	case CFG_UINT:
+		return "uint"
+	case CFG_UINT64:
+		return "uint64"
+	case CFG_FLOAT32:
+		return "float32"
+	case CFG_FLOAT64:
+		return "float64"
+	case CFG_STRINGLIST:
+		return "[]string"
+	case CFG_INTLIST:
+		return "[]int"
+	case CFG_DURATION:
+		return "time.Duration"
+	case CFG_TIME:
+		return "time.Time"
+	case CFG_DURATIONLIST:
+		return "[]time.Duration"
+	case CFG_BYTESIZE:
+		return "singleconfig.ByteSize"
+	case CFG_IP:
+		return "net.IP"
+	case CFG_CIDR:
+		return "*net.IPNet"
+	case CFG_HOSTPORT:
+		return "singleconfig.HostPort"
+	case CFG_URL:
+		return "*url.URL"
+	case CFG_IPLIST:
+		return "[]net.IP"
+	case CFG_CIDRLIST:
+		return "[]*net.IPNet"
+	case CFG_HOSTPORTLIST:
+		return "[]singleconfig.HostPort"
+	case CFG_URLLIST:
+		return "[]*url.URL"
+	case CFG_STRINGMAP:
+		return "map[string]string"
+	case CFG_INTMAP:
+		return "map[string]int"
+	case CFG_STRINGLISTLIST:
+		return "[][]string"
+	case CFG_INTLISTLIST:
+		return "[][]int"
+	case CFG_INT64LIST:
+		return "[]int64"
+	case CFG_UINT64LIST:
+		return "[]uint64"
+	case CFG_FLOAT64LIST:
+		return "[]float64"
+	case CFG_BOOLLIST:
+		return "[]bool"
+	}
+	return ""
+}
+
+// IsList reports whether values of the section are written as [a,b,c] lists
+func (t ConfigType) IsList() bool {
+	return strings.HasSuffix(string(t), "List")
+}
+
+// IsMap reports whether values of the section are written as {k:v} maps
+func (t ConfigType) IsMap() bool {
+	return strings.HasSuffix(string(t), "Map")
+}
+
+// ParseValue converts the raw text of a key in the section configType into its Go value
+func ParseValue(configType ConfigType, raw string) (value interface{}, err error) {
+	switch configType {
+	case CFG_STRING:
+		return raw, nil
+	case CFG_BOOL:
+		return parseBool(raw)
+	case CFG_INT:
+		return parseInt(raw)
+	case CFG_INT64:
+		return parseInt64(raw)
+	case CFG_UINT:
+		return parseUint(raw)
+	case CFG_UINT64:
+		return parseUint64(raw)
+	case CFG_FLOAT32:
+		return parseFloat32(raw)
+	case CFG_FLOAT64:
+		return parseFloat64(raw)
+	case CFG_STRINGLIST:
+		return parseStringList(raw)
+	case CFG_INTLIST:
+		return parseIntList(raw)
+	case CFG_DURATION:
+		return parseDuration(raw)
+	case CFG_TIME:
+		return parseTime(raw)
+	case CFG_DURATIONLIST:
+		return parseDurationList(raw)
+	case CFG_BYTESIZE:
+		return parseByteSize(raw)
+	case CFG_IP:
+		return parseIP(raw)
+	case CFG_CIDR:
+		return parseCIDR(raw)
+	case CFG_HOSTPORT:
+		return parseHostPort(raw)
+	case CFG_URL:
+		return parseURL(raw)
+	case CFG_IPLIST:
+		return parseIPList(raw)
+	case CFG_CIDRLIST:
+		return parseCIDRList(raw)
+	case CFG_HOSTPORTLIST:
+		return parseHostPortList(raw)
+	case CFG_URLLIST:
+		return parseURLList(raw)
+	case CFG_STRINGMAP:
+		return parseStringMap(raw)
+	case CFG_INTMAP:
+		return parseIntMap(raw)
+	case CFG_STRINGLISTLIST:
+		return parseStringListList(raw)
+	case CFG_INTLISTLIST:
+		return parseIntListList(raw)
+	case CFG_INT64LIST:
+		return parseInt64List(raw)
+	case CFG_UINT64LIST:
+		return parseUint64List(raw)
+	case CFG_FLOAT64LIST:
+		return parseFloat64List(raw)
+	case CFG_BOOLLIST:
+		return parseBoolList(raw)
+	}
+	return nil, fmt.Errorf("unknown config type %s", configType)
+}
+
+func parseBool(raw string) (bool, error) {
+	return strconv.ParseBool(raw)
+}
+
+func parseInt(raw string) (int, error) {
+	return strconv.Atoi(raw)
+}
+
+func parseInt64(raw string) (int64, error) {
+	return strconv.ParseInt(raw, 10, 64)
+}
+
+func parseUint(raw string) (uint, error) {
+	vUint, err := strconv.ParseUint(raw, 10, 0)
+	return uint(vUint), err
+}
+
+func parseUint64(raw string) (uint64, error) {
+	return strconv.ParseUint(raw, 10, 64)
+}
+
+func parseFloat32(raw string) (float32, error) {
+	vFloat32, err := strconv.ParseFloat(raw, 32)
+	return float32(vFloat32), err
+}
+
+func parseFloat64(raw string) (float64, error) {
+	return strconv.ParseFloat(raw, 64)
+}
+
+func parseDuration(raw string) (time.Duration, error) {
+	return time.ParseDuration(raw)
+}
+
+// parseTime reads an RFC3339 timestamp, the fraction of a second is optional
+func parseTime(raw string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, raw)
+}
+
+func parseStringList(raw string) ([]string, error) {
+	return splitList(raw)
+}
+
+func parseIntList(raw string) (ret []int, err error) {
+	ret = make([]int, 0)
+	err = eachListElem(raw, func(elem string) error {
+		vInt, err := parseInt(elem)
+		ret = append(ret, vInt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseInt64List(raw string) (ret []int64, err error) {
+	ret = make([]int64, 0)
+	err = eachListElem(raw, func(elem string) error {
+		vInt64, err := parseInt64(elem)
+		ret = append(ret, vInt64)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseUint64List(raw string) (ret []uint64, err error) {
+	ret = make([]uint64, 0)
+	err = eachListElem(raw, func(elem string) error {
+		vUint64, err := parseUint64(elem)
+		ret = append(ret, vUint64)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseFloat64List(raw string) (ret []float64, err error) {
+	ret = make([]float64, 0)
+	err = eachListElem(raw, func(elem string) error {
+		vFloat64, err := parseFloat64(elem)
+		ret = append(ret, vFloat64)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseBoolList(raw string) (ret []bool, err error) {
+	ret = make([]bool, 0)
+	err = eachListElem(raw, func(elem string) error {
+		vBool, err := parseBool(elem)
+		ret = append(ret, vBool)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func parseStringListList(raw string) ([][]string, error) {
+	return splitNestedList(raw)
+}
+
+func parseIntListList(raw string) (ret [][]int, err error) {
+	lists, err := splitNestedList(raw)
+	if err != nil {
+		return nil, err
+	}
+	ret = make([][]int, 0, len(lists))
+	for _, list := range lists {
+		vList := make([]int, 0, len(list))
+		err = eachElem(list, func(elem string) error {
+			vInt, err := parseInt(elem)
+			vList = append(vList, vInt)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, vList)
+	}
+	return ret, nil
+}
+
+func parseDurationList(raw string) (ret []time.Duration, err error) {
+	ret = make([]time.Duration, 0)
+	err = eachListElem(raw, func(elem string) error {
+		vDuration, err := parseDuration(elem)
+		ret = append(ret, vDuration)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// parseLenient converts raw like ParseConfig does: [] is an empty list, a
+// list of scalars keeps the elements that can be parsed, any other list is
+// dropped as a whole
+func parseLenient(configType ConfigType, raw string) (value interface{}, err error) {
+	if elemType, ok := listElemTypes[configType]; ok {
+		elems, err := splitList(raw)
+		if err != nil {
+			return nil, err
+		}
+		valid := make([]string, 0, len(elems))
+		for _, elem := range elems {
+			if _, err := ParseValue(elemType, elem); err == nil {
+				valid = append(valid, elem)
+			}
+		}
+		raw = joinList(valid)
+	}
+	return ParseValue(configType, raw)
+}
+
+// listElemTypes maps the lists of scalars to the section of their elements
+var listElemTypes = map[ConfigType]ConfigType{
+	CFG_INTLIST:      CFG_INT,
+	CFG_INT64LIST:    CFG_INT64,
+	CFG_UINT64LIST:   CFG_UINT64,
+	CFG_FLOAT64LIST:  CFG_FLOAT64,
+	CFG_BOOLLIST:     CFG_BOOL,
+	CFG_DURATIONLIST: CFG_DURATION,
+}
+
+// FormatValue converts a value of the section configType into the text
+// ParseValue reads back, a value whose text would not read back, etc: a nil
+// net.IP, is rejected
+func FormatValue(configType ConfigType, value interface{}) (raw string, err error) {
+	if hasNil(value) {
+		return "", fmt.Errorf("a nil %T can not be written to %s", value, configType)
+	}
+	if raw, err = formatValue(configType, value); err != nil {
+		return "", err
+	}
+	if _, err = ParseValue(configType, raw); err != nil {
+		return "", fmt.Errorf("%T %v can not be written to %s: %v", value, value, configType, err)
+	}
+	return raw, nil
+}
+
+// hasNil reports whether value is or holds a nil IP, CIDR or URL
+func hasNil(value interface{}) bool {
+	switch v := value.(type) {
+	case net.IP:
+		return v == nil
+	case *net.IPNet:
+		return v == nil
+	case *url.URL:
+		return v == nil
+	case []net.IP:
+		for _, elem := range v {
+			if elem == nil {
+				return true
+			}
+		}
+	case []*net.IPNet:
+		for _, elem := range v {
+			if elem == nil {
+				return true
+			}
+		}
+	case []*url.URL:
+		for _, elem := range v {
+			if elem == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatValue(configType ConfigType, value interface{}) (raw string, err error) {
+	switch v := value.(type) {
+	case string:
+		if configType == CFG_STRING {
+			return v, nil
+		}
+	case bool:
+		if configType == CFG_BOOL {
+			return strconv.FormatBool(v), nil
+		}
+	case int:
+		if configType == CFG_INT {
+			return strconv.Itoa(v), nil
+		}
+	case int64:
+		if configType == CFG_INT64 {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case uint:
+		if configType == CFG_UINT {
+			return strconv.FormatUint(uint64(v), 10), nil
+		}
+	case uint64:
+		if configType == CFG_UINT64 {
+			return strconv.FormatUint(v, 10), nil
+		}
+	case float32:
+		if configType == CFG_FLOAT32 {
+			return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+		}
+	case float64:
+		if configType == CFG_FLOAT64 {
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+	case []string:
+		if configType == CFG_STRINGLIST {
+			return joinList(v), nil
+		}
+	case []int:
+		if configType == CFG_INTLIST {
+			return formatList(v, strconv.Itoa), nil
+		}
+	case []int64:
+		if configType == CFG_INT64LIST {
+			return formatList(v, func(elem int64) string { return strconv.FormatInt(elem, 10) }), nil
+		}
+	case []uint64:
+		if configType == CFG_UINT64LIST {
+			return formatList(v, func(elem uint64) string { return strconv.FormatUint(elem, 10) }), nil
+		}
+	case []float64:
+		if configType == CFG_FLOAT64LIST {
+			return formatList(v, func(elem float64) string { return strconv.FormatFloat(elem, 'g', -1, 64) }), nil
+		}
+	case []bool:
+		if configType == CFG_BOOLLIST {
+			return formatList(v, strconv.FormatBool), nil
+		}
+	case [][]string:
+		if configType == CFG_STRINGLISTLIST {
+			return joinNestedList(v), nil
+		}
+	case [][]int:
+		if configType == CFG_INTLISTLIST {
+			return formatIntListList(v), nil
+		}
+	case time.Duration:
+		if configType == CFG_DURATION {
+			return v.String(), nil
+		}
+	case time.Time:
+		if configType == CFG_TIME {
+			return v.Format(time.RFC3339Nano), nil
+		}
+	case []time.Duration:
+		if configType == CFG_DURATIONLIST {
+			return formatList(v, time.Duration.String), nil
+		}
+	case ByteSize:
+		if configType == CFG_BYTESIZE {
+			return v.String(), nil
+		}
+	case net.IP:
+		if configType == CFG_IP {
+			return v.String(), nil
+		}
+	case *net.IPNet:
+		if configType == CFG_CIDR {
+			return v.String(), nil
+		}
+	case HostPort:
+		if configType == CFG_HOSTPORT {
+			return v.String(), nil
+		}
+	case *url.URL:
+		if configType == CFG_URL {
+			return v.String(), nil
+		}
+	case []net.IP:
+		if configType == CFG_IPLIST {
+			return formatList(v, net.IP.String), nil
+		}
+	case []*net.IPNet:
+		if configType == CFG_CIDRLIST {
+			return formatList(v, (*net.IPNet).String), nil
+		}
+	case []HostPort:
+		if configType == CFG_HOSTPORTLIST {
+			return formatList(v, HostPort.String), nil
+		}
+	case []*url.URL:
+		if configType == CFG_URLLIST {
+			return formatList(v, (*url.URL).String), nil
+		}
+	case map[string]string:
+		if configType == CFG_STRINGMAP {
+			return joinMap(v), nil
+		}
+	case map[string]int:
+		if configType == CFG_INTMAP {
+			return formatIntMap(v), nil
+		}
+	}
+	return "", fmt.Errorf("%T can not be written to %s", value, configType)
+}
+
+func formatIntListList(lists [][]int) string {
+	listToString := make([]string, 0, len(lists))
+	for _, list := range lists {
+		listToString = append(listToString, formatList(list, strconv.Itoa))
+	}
+	return fmt.Sprintf("[%v]", strings.Join(listToString, ","))
+}
@@ -0,0 +1,186 @@
+package singleconfig
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// stagedFile is new content written to a temporary file next to its target,
+// ready to be renamed over it
+type stagedFile struct {
+	target  string // the file filePath resolves to
+	tmp     string
+	backups int
+	old     []byte // content of target before the commit
+	existed bool
+}
+
+// writeFileAtomic replaces the file at filePath with data so that a crash
+// leaves either the old or the new content, never a truncated file
+func writeFileAtomic(filePath string, data []byte, backups int) error {
+	staged, err := stageFile(filePath, data, backups)
+	if err != nil {
+		return err
+	}
+	if renamed, err := staged.commit(); err != nil {
+		if !renamed {
+			staged.abort()
+		}
+		return err
+	}
+	return nil
+}
+
+// stageFile writes data to a temporary file in the directory of filePath and
+// syncs it. A symlink is followed, the file it points to is replaced. The
+// mode and, where the process may, the owner of the old file are kept.
+// With backups > 0 the commit keeps the old content as filePath.bak, older
+// ones are rotated to .bak.1 up to .bak.<backups-1>
+func stageFile(filePath string, data []byte, backups int) (staged *stagedFile, err error) {
+	target, err := resolveTarget(filePath)
+	if err != nil {
+		return nil, err
+	}
+	staged = &stagedFile{target: target, backups: backups}
+	mode := os.FileMode(0644)
+	info, err := os.Stat(target)
+	switch {
+	case err == nil:
+		mode = info.Mode().Perm()
+		if staged.old, err = ioutil.ReadFile(target); err != nil {
+			return nil, err
+		}
+		staged.existed = true
+	case os.IsNotExist(err):
+		info = nil
+	default:
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	staged.tmp = tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(staged.tmp)
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return nil, err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return nil, err
+	}
+	if info != nil {
+		if err = keepOwner(tmp, info); err != nil {
+			return nil, err
+		}
+	}
+	if err = tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	return staged, nil
+}
+
+// commit backs the target up if asked to and renames the staged file over
+// it. renamed reports whether the target holds the new content, even when
+// the directory could not be synced after the rename
+func (f *stagedFile) commit() (renamed bool, err error) {
+	if f.existed && f.backups > 0 {
+		if err = backupFile(f.target, f.backups); err != nil {
+			return false, err
+		}
+	}
+	if err = os.Rename(f.tmp, f.target); err != nil {
+		return false, err
+	}
+	return true, syncDir(filepath.Dir(f.target))
+}
+
+// abort removes the staged file of a write that is not committed
+func (f *stagedFile) abort() {
+	os.Remove(f.tmp)
+}
+
+// rollback puts the content the target had before the commit back, a target
+// that did not exist is removed. Backups made by the commit are kept
+func (f *stagedFile) rollback() error {
+	if !f.existed {
+		if err := os.Remove(f.target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return syncDir(filepath.Dir(f.target))
+	}
+	return writeFileAtomic(f.target, f.old, 0)
+}
+
+// resolveTarget follows the symlinks of filePath, a missing file is its own target
+func resolveTarget(filePath string) (target string, err error) {
+	target, err = filepath.EvalSymlinks(filePath)
+	if os.IsNotExist(err) {
+		if link, linkErr := os.Readlink(filePath); linkErr == nil {
+			// dangling symlink, create the file it points to
+			if !filepath.IsAbs(link) {
+				link = filepath.Join(filepath.Dir(filePath), link)
+			}
+			return link, nil
+		}
+		return filePath, nil
+	}
+	return target, err
+}
+
+// backupFile rotates the backups of target and keeps its current content as
+// target.bak, at most backups files are kept
+func backupFile(target string, backups int) error {
+	name := func(i int) string {
+		if i == 0 {
+			return target + ".bak"
+		}
+		return fmt.Sprintf("%s.bak.%d", target, i)
+	}
+	if err := os.Remove(name(backups - 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := backups - 2; i >= 0; i-- {
+		if err := os.Rename(name(i), name(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return copyFile(target, name(0))
+}
+
+// copyFile copies src to dst with the mode of src, dst is synced
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
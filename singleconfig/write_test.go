@@ -0,0 +1,217 @@
+package singleconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// readFile returns the content of filePath, "" if it can not be read
+func readFile(t *testing.T, filePath string) string {
+	t.Helper()
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// checkNoTemp fails if a staged file is left in dir
+func checkNoTemp(t *testing.T, dir string) {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(dir, ".*.tmp*"))
+	if len(matches) > 0 {
+		t.Errorf("staged files are left: %q", matches)
+	}
+}
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not kept on windows")
+	}
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.conf")
+	if err := os.WriteFile(filePath, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filePath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(filePath, []byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filePath); got != "new" {
+		t.Errorf("the file holds %q", got)
+	}
+	if info, err := os.Stat(filePath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("the mode is %v, want -rw-------: %v", info.Mode(), err)
+	}
+
+	created := filepath.Join(dir, "new.conf")
+	if err := writeFileAtomic(created, []byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(created); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("a new file has the mode %v, want -rw-r--r--: %v", info.Mode(), err)
+	}
+	checkNoTemp(t, dir)
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.conf")
+	link := filepath.Join(dir, "config.conf")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real.conf", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(link, []byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, target); got != "new" {
+		t.Errorf("the target holds %q", got)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the symlink was replaced: %v", err)
+	}
+
+	// a dangling symlink creates the file it points to
+	dangling := filepath.Join(dir, "dangling.conf")
+	if err := os.Symlink(filepath.Join("sub", "missing.conf"), dangling); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(dangling, []byte("created"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "sub", "missing.conf")); got != "created" {
+		t.Errorf("the dangling target holds %q", got)
+	}
+	if info, err := os.Lstat(dangling); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the dangling symlink was replaced: %v", err)
+	}
+	checkNoTemp(t, dir)
+	checkNoTemp(t, filepath.Join(dir, "sub"))
+}
+
+func TestBackupRotation(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.conf")
+	if err := os.WriteFile(filePath, []byte("[sectionInt]\nN = 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadSingleConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SetBackups(3)
+	for i := 1; i <= 4; i++ {
+		setValues(t, config, "N", i)
+		if err := config.FlushToConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]string{
+		filePath:            "[sectionInt]\nN = 4\n",
+		filePath + ".bak":   "[sectionInt]\nN = 3\n",
+		filePath + ".bak.1": "[sectionInt]\nN = 2\n",
+		filePath + ".bak.2": "[sectionInt]\nN = 1\n",
+		filePath + ".bak.3": "",
+	}
+	for name, content := range want {
+		if got := readFile(t, name); got != content {
+			t.Errorf("%s holds %q, want %q", filepath.Base(name), got, content)
+		}
+	}
+
+	// without changes nothing is written or rotated
+	if err := config.FlushToConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filePath+".bak"); got != want[filePath+".bak"] {
+		t.Errorf("a flush without changes rotated the backups, .bak holds %q", got)
+	}
+	checkNoTemp(t, dir)
+}
+
+func TestStagedFileRollback(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.conf")
+	if err := os.WriteFile(filePath, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := stageFile(filePath, []byte("new"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filePath); got != "old" {
+		t.Errorf("staging changed the file to %q", got)
+	}
+	if renamed, err := staged.commit(); !renamed || err != nil {
+		t.Fatalf("commit = %v, %v", renamed, err)
+	}
+	if err := staged.rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filePath); got != "old" {
+		t.Errorf("the rolled back file holds %q", got)
+	}
+	if got := readFile(t, filePath+".bak"); got != "old" {
+		t.Errorf("the backup holds %q", got)
+	}
+
+	// a file the commit created is removed
+	created := filepath.Join(dir, "new.conf")
+	if staged, err = stageFile(created, []byte("new"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := staged.commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := staged.rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("the created file is left: %v", err)
+	}
+
+	// an aborted stage leaves nothing behind
+	if staged, err = stageFile(filePath, []byte("aborted"), 0); err != nil {
+		t.Fatal(err)
+	}
+	staged.abort()
+	if got := readFile(t, filePath); got != "old" {
+		t.Errorf("an aborted stage changed the file to %q", got)
+	}
+	checkNoTemp(t, dir)
+}
+
+func TestBackupFileMissing(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "config.conf")
+	if err := os.WriteFile(filePath, []byte("v"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// rotating before any backup exists only creates .bak
+	if err := backupFile(filePath, 3); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"v", "", ""} {
+		name := filePath + ".bak"
+		if i > 0 {
+			name = fmt.Sprintf("%s.bak.%d", filePath, i)
+		}
+		if got := readFile(t, name); got != want {
+			t.Errorf("%s holds %q, want %q", filepath.Base(name), got, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+// +build !windows
+
+package singleconfig
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives f the owner and group of info, a process that is not
+// allowed to, etc: not root, leaves them as they are
+func keepOwner(f *os.File, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := f.Chown(int(stat.Uid), int(stat.Gid)); err != nil && !os.IsPermission(err) {
+		return err
+	}
+	return nil
+}
+
+// syncDir makes a rename in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err = d.Sync(); err != nil && err != syscall.EINVAL {
+		return err
+	}
+	return nil
+}
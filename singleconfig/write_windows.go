@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package singleconfig
+
+import (
+	"os"
+)
+
+// keepOwner does nothing, a new file inherits the ACL of its directory
+func keepOwner(f *os.File, info os.FileInfo) error {
+	return nil
+}
+
+// syncDir does nothing, directories can not be synced on windows
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,384 @@
+package singleconfig
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// decodeYAML reads a YAML mapping. Top level keys named like a typed section
+// hold that section, every other key is placed by its YAML type: ints,
+// floats, bools, strings and sequences. Nested mappings are flattened into
+// dotted keys, etc: server.port
+func decodeYAML(filePath string, data []byte) (doc *document, err error) {
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		line := 0
+		if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+			line, _ = strconv.Atoi(match[1])
+		}
+		return nil, &ConfigError{File: filePath, Kind: ERR_SYNTAX, Line: line, Content: lineContent(data, line), Err: err}
+	}
+	doc = newDocument()
+	doc.yaml = &root
+	if len(root.Content) == 0 {
+		return doc, nil
+	}
+	top := yamlAlias(root.Content[0])
+	if top.Kind != yaml.MappingNode {
+		return nil, yamlError(filePath, data, top, fmt.Errorf("top level must be a mapping"))
+	}
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		key, value := top.Content[i].Value, yamlAlias(top.Content[i+1])
+		if isTypedSection(key) && value.Kind == yaml.MappingNode {
+			doc.cfg.SetValue(key, " ", " ")
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				sectionKey, node := value.Content[j].Value, yamlAlias(value.Content[j+1])
+				if node.ShortTag() == "!!null" {
+					continue
+				}
+				var raw string
+				if ConfigType(key).IsMap() && node.Kind == yaml.MappingNode {
+					raw, err = yamlMap(node)
+				} else {
+					_, raw, err = yamlValue(node)
+				}
+				if err != nil {
+					return nil, yamlError(filePath, data, node, fmt.Errorf("[%s] %s: %v", key, sectionKey, err))
+				}
+				doc.cfg.SetValue(key, sectionKey, raw)
+				doc.setLine(key, sectionKey, value.Content[j].Line)
+				doc.setPath(key, sectionKey, []string{key, sectionKey})
+			}
+			continue
+		}
+		if err = decodeYAMLNative(filePath, data, doc, []string{key}, top.Content[i].Line, value); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// decodeYAMLNative places the value of the key path found at line
+func decodeYAMLNative(filePath string, data []byte, doc *document, path []string, line int, node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := append(append([]string{}, path...), node.Content[i].Value)
+			if err := decodeYAMLNative(filePath, data, doc, childPath, node.Content[i].Line, yamlAlias(node.Content[i+1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if node.ShortTag() == "!!null" {
+		return nil
+	}
+	key := strings.Join(path, ".")
+	configType, raw, err := yamlValue(node)
+	if err != nil {
+		return yamlError(filePath, data, node, fmt.Errorf("%s: %v", key, err))
+	}
+	doc.cfg.SetValue(string(configType), key, raw)
+	doc.setLine(string(configType), key, line)
+	doc.setPath(string(configType), key, path)
+	return nil
+}
+
+func yamlError(filePath string, data []byte, node *yaml.Node, err error) *ConfigError {
+	return &ConfigError{File: filePath, Kind: ERR_SYNTAX, Line: node.Line, Content: lineContent(data, node.Line), Err: err}
+}
+
+// yamlAlias follows an alias to the node it refers to
+func yamlAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// yamlValue returns the section a scalar or sequence belongs in and its INI text
+func yamlValue(node *yaml.Node) (configType ConfigType, raw string, err error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return yamlScalar(node)
+	case yaml.SequenceNode:
+		seq := &sequence{}
+		for _, elem := range node.Content {
+			elem = yamlAlias(elem)
+			if elem.Kind == yaml.MappingNode {
+				return "", "", fmt.Errorf("only sequences of scalars or sequences are supported")
+			}
+			elemType, elemRaw, err := yamlValue(elem)
+			if err != nil {
+				return "", "", err
+			}
+			seq.add(elemType, elemRaw)
+		}
+		return seq.result()
+	}
+	return "", "", fmt.Errorf("mappings are not supported here")
+}
+
+// yamlMap converts a mapping of scalars to the INI text of a map
+func yamlMap(node *yaml.Node) (raw string, err error) {
+	entries := make(map[string]string)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := yamlAlias(node.Content[i+1])
+		if value.Kind != yaml.ScalarNode {
+			return "", fmt.Errorf("%s: only scalars are supported in a map", node.Content[i].Value)
+		}
+		_, text, err := yamlScalar(value)
+		if err != nil {
+			return "", err
+		}
+		entries[node.Content[i].Value] = text
+	}
+	return joinMap(entries), nil
+}
+
+func yamlScalar(node *yaml.Node) (configType ConfigType, raw string, err error) {
+	switch node.ShortTag() {
+	case "!!int":
+		var vInt int
+		if err = node.Decode(&vInt); err == nil {
+			return CFG_INT, strconv.Itoa(vInt), nil
+		}
+		var vUint64 uint64
+		if err = node.Decode(&vUint64); err != nil {
+			return "", "", err
+		}
+		return CFG_UINT64, strconv.FormatUint(vUint64, 10), nil
+	case "!!float":
+		var vFloat64 float64
+		if err = node.Decode(&vFloat64); err != nil {
+			return "", "", err
+		}
+		return CFG_FLOAT64, formatNativeFloat(vFloat64, 64), nil
+	case "!!bool":
+		var vBool bool
+		if err = node.Decode(&vBool); err != nil {
+			return "", "", err
+		}
+		return CFG_BOOL, strconv.FormatBool(vBool), nil
+	case "!!timestamp":
+		var vTime time.Time
+		if err = node.Decode(&vTime); err != nil {
+			return "", "", err
+		}
+		return CFG_TIME, vTime.Format(time.RFC3339Nano), nil
+	}
+	return CFG_STRING, node.Value, nil
+}
+
+// encodeYAML writes the values into the node tree of the file, so comments
+// and the order of keys survive a flush. A key keeps the place it was read
+// from, a new key of a native type is written as a plain YAML value and any
+// other new key into its typed section
+func encodeYAML(doc *document) (data []byte, err error) {
+	if doc.yaml == nil || len(doc.yaml.Content) == 0 {
+		doc.yaml = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := yamlAlias(doc.yaml.Content[0])
+	for _, section := range doc.cfg.GetSectionList() {
+		configType := ConfigType(section)
+		values, _ := doc.cfg.GetSection(section)
+		for _, key := range doc.cfg.GetKeyList(section) {
+			raw, ok := values[key]
+			if !ok {
+				continue
+			}
+			path := doc.paths[section][key]
+			if path == nil && isNative(configType) {
+				path = strings.Split(key, ".")
+			} else if path == nil {
+				path = []string{section, key}
+			}
+			if err = setYAMLValue(root, path, configType, raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(doc.yaml); err != nil {
+		return nil, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setYAMLValue stores raw under path, a node that already holds the value is
+// left as it is and a changed one keeps its comments and style
+func setYAMLValue(root *yaml.Node, path []string, configType ConfigType, raw string) error {
+	old, shared := yamlLookup(root, path)
+	if old != nil {
+		var text string
+		var err error
+		if configType.IsMap() && old.Kind == yaml.MappingNode {
+			text, err = yamlMap(old)
+		} else {
+			_, text, err = yamlValue(old)
+		}
+		if err == nil && sameValue(configType, text, raw) {
+			return nil
+		}
+	}
+	if shared {
+		return fmt.Errorf("%s: a value shared through a YAML anchor can not be changed", strings.Join(path, "."))
+	}
+	node := yamlNode(configType, raw)
+	if old == nil {
+		setYAMLPath(root, path, node)
+		return nil
+	}
+	node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	if old.Kind == node.Kind && (node.Kind == yaml.SequenceNode || node.Kind == yaml.MappingNode || node.Tag == "!!str") {
+		node.Style = old.Style
+	}
+	*old = *node
+	return nil
+}
+
+// yamlLookup returns the node under the nested mapping keys of path, shared
+// reports whether it, or a mapping on the way to it, is an anchor or alias
+func yamlLookup(mapping *yaml.Node, path []string) (node *yaml.Node, shared bool) {
+	for _, key := range path {
+		if mapping.Kind != yaml.MappingNode {
+			return nil, shared
+		}
+		node = nil
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value == key {
+				node = mapping.Content[i+1]
+			}
+		}
+		if node == nil {
+			return nil, shared
+		}
+		shared = shared || node.Kind == yaml.AliasNode || node.Anchor != ""
+		mapping = yamlAlias(node)
+	}
+	return node, shared
+}
+
+// setYAMLPath stores node under the nested mapping keys of path
+func setYAMLPath(mapping *yaml.Node, path []string, node *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			mapping.Content[i+1] = node
+			return
+		}
+		if mapping.Content[i+1].Kind != yaml.MappingNode {
+			mapping.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode}
+		}
+		setYAMLPath(mapping.Content[i+1], path[1:], node)
+		return
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, keyNode, node)
+		return
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, keyNode, child)
+	setYAMLPath(child, path[1:], node)
+}
+
+// yamlNode converts the INI text of a value of the section to a typed YAML node
+func yamlNode(configType ConfigType, raw string) *yaml.Node {
+	value, err := ParseValue(configType, raw)
+	if err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: raw}
+	}
+	switch v := value.(type) {
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case int, int64, uint, uint64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprint(v)}
+	case float32:
+		return yamlFloat(float64(v), 32)
+	case float64:
+		return yamlFloat(v, 64)
+	case []string:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, elem := range v {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: elem})
+		}
+		return seq
+	case []int:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, elem := range v {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(elem)})
+		}
+		return seq
+	case [][]string:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, list := range v {
+			seq.Content = append(seq.Content, yamlNode(CFG_STRINGLIST, joinList(list)))
+		}
+		return seq
+	case [][]int:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, list := range v {
+			seq.Content = append(seq.Content, yamlNode(CFG_INTLIST, formatList(list, strconv.Itoa)))
+		}
+		return seq
+	case time.Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: v.Format(time.RFC3339Nano)}
+	case map[string]string:
+		mapping := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+		for _, k := range sortedKeys(v) {
+			setYAMLPath(mapping, []string{k}, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v[k]})
+		}
+		return mapping
+	case map[string]int:
+		mapping := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+		texts := make(map[string]string, len(v))
+		for k, entry := range v {
+			texts[k] = strconv.Itoa(entry)
+		}
+		for _, k := range sortedKeys(texts) {
+			setYAMLPath(mapping, []string{k}, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: texts[k]})
+		}
+		return mapping
+	}
+	if elems, err := splitList(raw); err == nil && configType.IsList() {
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, elem := range elems {
+			if elemType, ok := listElemTypes[configType]; ok {
+				seq.Content = append(seq.Content, yamlNode(elemType, elem))
+			} else {
+				seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: elem})
+			}
+		}
+		return seq
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: raw}
+}
+
+func yamlFloat(f float64, bitSize int) *yaml.Node {
+	raw := formatNativeFloat(f, bitSize)
+	switch {
+	case math.IsInf(f, 1):
+		raw = ".inf"
+	case math.IsInf(f, -1):
+		raw = "-.inf"
+	case math.IsNaN(f):
+		raw = ".nan"
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: raw}
+}
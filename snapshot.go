@@ -0,0 +1,386 @@
+package multiconfig
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// Snapshot is the merged configuration of every layer at one point in time.
+// It is immutable, every ParseXxx method returns a fresh copy, so it can be
+// shared between goroutines freely
+type Snapshot struct {
+	values map[singleconfig.ConfigType]map[string]interface{}
+	coerce bool // Get and Unmarshal convert a key found in another section
+}
+
+// buildSnapshot merges layers in order, later layers override earlier ones,
+// map values are combined as mapMerge says
+func buildSnapshot(layers []*singleconfig.SingleConfig, mapMerge MapMerge) Snapshot {
+	values := make(map[singleconfig.ConfigType]map[string]interface{})
+	for _, configType := range singleconfig.ConfigTypes {
+		values[configType] = make(map[string]interface{})
+		for _, layer := range layers {
+			for k, v := range layer.Values(configType) {
+				setValue(values, configType, k, v, mapMerge)
+			}
+		}
+	}
+	return Snapshot{values: values}
+}
+
+// setValue puts value of a higher layer over values, a map is merged entry by
+// entry into a new map unless mapMerge is MAP_REPLACE
+func setValue(values map[singleconfig.ConfigType]map[string]interface{}, configType singleconfig.ConfigType, key string, value interface{}, mapMerge MapMerge) {
+	if mapMerge == MAP_MERGE_ENTRIES {
+		switch v := value.(type) {
+		case map[string]string:
+			if lower, ok := values[configType][key].(map[string]string); ok {
+				merged := clone(lower).(map[string]string)
+				for k, entry := range v {
+					merged[k] = entry
+				}
+				value = merged
+			}
+		case map[string]int:
+			if lower, ok := values[configType][key].(map[string]int); ok {
+				merged := clone(lower).(map[string]int)
+				for k, entry := range v {
+					merged[k] = entry
+				}
+				value = merged
+			}
+		}
+	}
+	values[configType][key] = value
+}
+
+// Equal reports whether both snapshots hold the same keys and values
+func (s Snapshot) Equal(other Snapshot) bool {
+	return reflect.DeepEqual(s.values, other.values)
+}
+
+// Keys returns every key of the section configType
+func (s Snapshot) Keys(configType singleconfig.ConfigType) []string {
+	keys := make([]string, 0, len(s.values[configType]))
+	for k := range s.values[configType] {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (s Snapshot) ParseString() map[string]string {
+	ret := make(map[string]string)
+	for k, v := range s.values[singleconfig.CFG_STRING] {
+		ret[k] = v.(string)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseBool() map[string]bool {
+	ret := make(map[string]bool)
+	for k, v := range s.values[singleconfig.CFG_BOOL] {
+		ret[k] = v.(bool)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseInt() map[string]int {
+	ret := make(map[string]int)
+	for k, v := range s.values[singleconfig.CFG_INT] {
+		ret[k] = v.(int)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseInt64() map[string]int64 {
+	ret := make(map[string]int64)
+	for k, v := range s.values[singleconfig.CFG_INT64] {
+		ret[k] = v.(int64)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseUint() map[string]uint {
+	ret := make(map[string]uint)
+	for k, v := range s.values[singleconfig.CFG_UINT] {
+		ret[k] = v.(uint)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseUint64() map[string]uint64 {
+	ret := make(map[string]uint64)
+	for k, v := range s.values[singleconfig.CFG_UINT64] {
+		ret[k] = v.(uint64)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseFloat32() map[string]float32 {
+	ret := make(map[string]float32)
+	for k, v := range s.values[singleconfig.CFG_FLOAT32] {
+		ret[k] = v.(float32)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseFloat64() map[string]float64 {
+	ret := make(map[string]float64)
+	for k, v := range s.values[singleconfig.CFG_FLOAT64] {
+		ret[k] = v.(float64)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseStringList() map[string][]string {
+	ret := make(map[string][]string)
+	for k, v := range s.values[singleconfig.CFG_STRINGLIST] {
+		ret[k] = append([]string(nil), v.([]string)...)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseIntList() map[string][]int {
+	ret := make(map[string][]int)
+	for k, v := range s.values[singleconfig.CFG_INTLIST] {
+		ret[k] = append([]int(nil), v.([]int)...)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseDuration() map[string]time.Duration {
+	ret := make(map[string]time.Duration)
+	for k, v := range s.values[singleconfig.CFG_DURATION] {
+		ret[k] = v.(time.Duration)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseTime() map[string]time.Time {
+	ret := make(map[string]time.Time)
+	for k, v := range s.values[singleconfig.CFG_TIME] {
+		ret[k] = v.(time.Time)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseDurationList() map[string][]time.Duration {
+	ret := make(map[string][]time.Duration)
+	for k, v := range s.values[singleconfig.CFG_DURATIONLIST] {
+		ret[k] = append([]time.Duration(nil), v.([]time.Duration)...)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseByteSize() map[string]singleconfig.ByteSize {
+	ret := make(map[string]singleconfig.ByteSize)
+	for k, v := range s.values[singleconfig.CFG_BYTESIZE] {
+		ret[k] = v.(singleconfig.ByteSize)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseIP() map[string]net.IP {
+	ret := make(map[string]net.IP)
+	for k, v := range s.values[singleconfig.CFG_IP] {
+		ret[k] = clone(v).(net.IP)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseCIDR() map[string]*net.IPNet {
+	ret := make(map[string]*net.IPNet)
+	for k, v := range s.values[singleconfig.CFG_CIDR] {
+		ret[k] = clone(v).(*net.IPNet)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseHostPort() map[string]singleconfig.HostPort {
+	ret := make(map[string]singleconfig.HostPort)
+	for k, v := range s.values[singleconfig.CFG_HOSTPORT] {
+		ret[k] = clone(v).(singleconfig.HostPort)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseURL() map[string]*url.URL {
+	ret := make(map[string]*url.URL)
+	for k, v := range s.values[singleconfig.CFG_URL] {
+		ret[k] = clone(v).(*url.URL)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseIPList() map[string][]net.IP {
+	ret := make(map[string][]net.IP)
+	for k, v := range s.values[singleconfig.CFG_IPLIST] {
+		ret[k] = clone(v).([]net.IP)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseCIDRList() map[string][]*net.IPNet {
+	ret := make(map[string][]*net.IPNet)
+	for k, v := range s.values[singleconfig.CFG_CIDRLIST] {
+		ret[k] = clone(v).([]*net.IPNet)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseHostPortList() map[string][]singleconfig.HostPort {
+	ret := make(map[string][]singleconfig.HostPort)
+	for k, v := range s.values[singleconfig.CFG_HOSTPORTLIST] {
+		ret[k] = clone(v).([]singleconfig.HostPort)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseURLList() map[string][]*url.URL {
+	ret := make(map[string][]*url.URL)
+	for k, v := range s.values[singleconfig.CFG_URLLIST] {
+		ret[k] = clone(v).([]*url.URL)
+	}
+	return ret
+}
+
+// clone copies the values that share memory, lists, maps, net.IP,
+// *net.IPNet and *url.URL, so a caller can not change the snapshot
+func clone(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []string:
+		return append(make([]string, 0, len(v)), v...)
+	case []int:
+		return append(make([]int, 0, len(v)), v...)
+	case []int64:
+		return append(make([]int64, 0, len(v)), v...)
+	case []uint64:
+		return append(make([]uint64, 0, len(v)), v...)
+	case []float64:
+		return append(make([]float64, 0, len(v)), v...)
+	case []bool:
+		return append(make([]bool, 0, len(v)), v...)
+	case []time.Duration:
+		return append(make([]time.Duration, 0, len(v)), v...)
+	case [][]string:
+		ret := make([][]string, 0, len(v))
+		for _, list := range v {
+			ret = append(ret, clone(list).([]string))
+		}
+		return ret
+	case [][]int:
+		ret := make([][]int, 0, len(v))
+		for _, list := range v {
+			ret = append(ret, clone(list).([]int))
+		}
+		return ret
+	case net.IP:
+		return append(net.IP(nil), v...)
+	case *net.IPNet:
+		return &net.IPNet{IP: append(net.IP(nil), v.IP...), Mask: append(net.IPMask(nil), v.Mask...)}
+	case *url.URL:
+		u := *v
+		return &u
+	case []net.IP:
+		ret := make([]net.IP, 0, len(v))
+		for _, ip := range v {
+			ret = append(ret, clone(ip).(net.IP))
+		}
+		return ret
+	case []*net.IPNet:
+		ret := make([]*net.IPNet, 0, len(v))
+		for _, ipNet := range v {
+			ret = append(ret, clone(ipNet).(*net.IPNet))
+		}
+		return ret
+	case map[string]string:
+		ret := make(map[string]string, len(v))
+		for k, entry := range v {
+			ret[k] = entry
+		}
+		return ret
+	case map[string]int:
+		ret := make(map[string]int, len(v))
+		for k, entry := range v {
+			ret[k] = entry
+		}
+		return ret
+	case []singleconfig.HostPort:
+		return append(make([]singleconfig.HostPort, 0, len(v)), v...)
+	case []*url.URL:
+		ret := make([]*url.URL, 0, len(v))
+		for _, u := range v {
+			ret = append(ret, clone(u).(*url.URL))
+		}
+		return ret
+	}
+	return value
+}
+
+func (s Snapshot) ParseStringMap() map[string]map[string]string {
+	ret := make(map[string]map[string]string)
+	for k, v := range s.values[singleconfig.CFG_STRINGMAP] {
+		ret[k] = clone(v).(map[string]string)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseIntMap() map[string]map[string]int {
+	ret := make(map[string]map[string]int)
+	for k, v := range s.values[singleconfig.CFG_INTMAP] {
+		ret[k] = clone(v).(map[string]int)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseStringListList() map[string][][]string {
+	ret := make(map[string][][]string)
+	for k, v := range s.values[singleconfig.CFG_STRINGLISTLIST] {
+		ret[k] = clone(v).([][]string)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseIntListList() map[string][][]int {
+	ret := make(map[string][][]int)
+	for k, v := range s.values[singleconfig.CFG_INTLISTLIST] {
+		ret[k] = clone(v).([][]int)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseInt64List() map[string][]int64 {
+	ret := make(map[string][]int64)
+	for k, v := range s.values[singleconfig.CFG_INT64LIST] {
+		ret[k] = clone(v).([]int64)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseUint64List() map[string][]uint64 {
+	ret := make(map[string][]uint64)
+	for k, v := range s.values[singleconfig.CFG_UINT64LIST] {
+		ret[k] = clone(v).([]uint64)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseFloat64List() map[string][]float64 {
+	ret := make(map[string][]float64)
+	for k, v := range s.values[singleconfig.CFG_FLOAT64LIST] {
+		ret[k] = clone(v).([]float64)
+	}
+	return ret
+}
+
+func (s Snapshot) ParseBoolList() map[string][]bool {
+	ret := make(map[string][]bool)
+	for k, v := range s.values[singleconfig.CFG_BOOLLIST] {
+		ret[k] = clone(v).([]bool)
+	}
+	return ret
+}
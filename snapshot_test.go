@@ -0,0 +1,233 @@
+package multiconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeVersion writes a file whose keys of three sections all hold version i
+func writeVersion(t *testing.T, filePath string, i int) {
+	t.Helper()
+	data := fmt.Sprintf("[sectionInt]\nN = %d\n\n[sectionString]\nS = %d\n\n[sectionIntList]\nL = [%d,%d]\n", i, i, i, i)
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	dir := t.TempDir()
+	base, temp := filepath.Join(dir, "config.conf"), filepath.Join(dir, "temp.conf")
+	writeVersion(t, base, 0)
+	if err := os.WriteFile(temp, []byte("[sectionInt]\nT = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMultiConfig(base, temp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.OnChange(func(old, new Snapshot) {})
+	m.OnError(func(err error) {})
+	m.Watch(time.Millisecond)
+	defer m.StopWatch()
+
+	const rounds = 100
+	var writers, readers sync.WaitGroup
+	stop := make(chan struct{})
+	writers.Add(3)
+	go func() {
+		defer writers.Done()
+		for i := 0; i < rounds; i++ {
+			if err := m.SetValue("T", i, temp); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer writers.Done()
+		for i := 0; i < rounds; i++ {
+			m.SetValue("N", i, "")
+			m.SetValue("L", []int{i}, "")
+		}
+	}()
+	go func() {
+		defer writers.Done()
+		for i := 0; i < rounds/10; i++ {
+			writeVersion(t, base, i)
+			if err := m.Reload(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				snapshot := m.Snapshot()
+				snapshot.ParseInt()
+				snapshot.ParseIntList()
+				m.ParseString()
+				m.Explain("N")
+				GetOr(m, "N", 0)
+				GetOr[[]int](m, "L", nil)
+				if _, ok := Get[int](m, "T"); !ok {
+					t.Error("T is missing")
+				}
+			}
+		}()
+	}
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	// Reload drops values not flushed, so only the state after the writers is known
+	m.SetValue("T", rounds, temp)
+	if got := MustGet[int](m, "T"); got != rounds {
+		t.Errorf("T = %d, want %d", got, rounds)
+	}
+}
+
+func TestSnapshotConsistent(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	writeVersion(t, filePath, 0)
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const versions = 50
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= versions; i++ {
+			writeVersion(t, filePath, i)
+			if err := m.Reload(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				snapshot := m.Snapshot()
+				n := snapshot.ParseInt()["N"]
+				if s := snapshot.ParseString()["S"]; s != strconv.Itoa(n) {
+					t.Errorf("one snapshot holds N = %d and S = %s", n, s)
+					return
+				}
+				if l := snapshot.ParseIntList()["L"]; !reflect.DeepEqual(l, []int{n, n}) {
+					t.Errorf("one snapshot holds N = %d and L = %v", n, l)
+					return
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+	}
+	readers.Wait()
+
+	if n := m.Snapshot().ParseInt()["N"]; n != versions {
+		t.Errorf("N = %d after the last reload, want %d", n, versions)
+	}
+}
+
+func TestSnapshotIsolated(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	writeVersion(t, filePath, 1)
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := m.Snapshot()
+	snapshot.ParseIntList()["L"][0] = 99
+	m.SetValue("N", 2, "")
+	if n := snapshot.ParseInt()["N"]; n != 1 {
+		t.Errorf("SetValue changed an earlier snapshot, N = %d", n)
+	}
+	if l := m.Snapshot().ParseIntList()["L"]; !reflect.DeepEqual(l, []int{1, 1}) {
+		t.Errorf("changing a returned list changed the snapshot, L = %v", l)
+	}
+}
+
+func TestOnChangeOrder(t *testing.T) {
+	for trial := 0; trial < 20; trial++ {
+		filePath := filepath.Join(t.TempDir(), "config.conf")
+		writeVersion(t, filePath, 0)
+		m, err := LoadMultiConfig(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lock sync.Mutex
+		last := m.Snapshot()
+		m.OnChange(func(old, new Snapshot) {
+			// give a later change the chance to overtake this one
+			time.Sleep(time.Microsecond)
+			lock.Lock()
+			defer lock.Unlock()
+			if !old.Equal(last) {
+				t.Error("a change was delivered out of order")
+			}
+			last = new
+		})
+
+		var writers sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			writers.Add(1)
+			go func(w int) {
+				defer writers.Done()
+				for i := 0; i < 20; i++ {
+					m.SetValue("N", w*100+i, "")
+				}
+			}(w)
+		}
+		writers.Wait()
+
+		lock.Lock()
+		if !last.Equal(m.Snapshot()) {
+			t.Errorf("the last change delivered holds N = %d, the snapshot N = %d", last.ParseInt()["N"], m.Snapshot().ParseInt()["N"])
+		}
+		lock.Unlock()
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+func TestOnChangeReentrant(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	writeVersion(t, filePath, 0)
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []int
+	m.OnChange(func(old, new Snapshot) {
+		n := new.ParseInt()["N"]
+		seen = append(seen, n)
+		if n < 3 {
+			// a listener may change the configuration itself
+			m.SetValue("N", n+1, "")
+		}
+	})
+	m.SetValue("N", 1, "")
+	if !reflect.DeepEqual(seen, []int{1, 2, 3}) {
+		t.Errorf("changes delivered as %v, want [1 2 3]", seen)
+	}
+}
@@ -0,0 +1,238 @@
+package multiconfig
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// ErrKeyNotFound is reported for a required key that no layer defines
+var ErrKeyNotFound = errors.New("key not found")
+
+// TypeError is reported when a key exists, but not in the section the Go type asks for
+type TypeError struct {
+	Key   string
+	Want  singleconfig.ConfigType   // section matching the requested Go type
+	Found []singleconfig.ConfigType // sections the key is defined in
+}
+
+func (e *TypeError) Error() string {
+	found := make([]string, 0, len(e.Found))
+	for _, configType := range e.Found {
+		found = append(found, string(configType))
+	}
+	return fmt.Sprintf("key %s is defined in %s, not in %s", e.Key, strings.Join(found, ","), e.Want)
+}
+
+// FieldError describes a struct field Unmarshal could not fill
+type FieldError struct {
+	Field string // path of the field, etc: Server.Port
+	Key   string // configuration key of the field
+	Err   error  // ErrKeyNotFound, a *TypeError or an unsupported type
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s (key %s): %v", e.Field, e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalError collects every field Unmarshal could not fill
+type UnmarshalError []*FieldError
+
+func (e UnmarshalError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		msgs = append(msgs, fieldErr.Error())
+	}
+	return fmt.Sprintf("multiconfig: unmarshal: %s", strings.Join(msgs, "; "))
+}
+
+// goTypes maps the Go types Unmarshal can fill to their typed section
+var goTypes = map[reflect.Type]singleconfig.ConfigType{
+	reflect.TypeOf(""):                        singleconfig.CFG_STRING,
+	reflect.TypeOf(false):                     singleconfig.CFG_BOOL,
+	reflect.TypeOf(int(0)):                    singleconfig.CFG_INT,
+	reflect.TypeOf(int64(0)):                  singleconfig.CFG_INT64,
+	reflect.TypeOf(uint(0)):                   singleconfig.CFG_UINT,
+	reflect.TypeOf(uint64(0)):                 singleconfig.CFG_UINT64,
+	reflect.TypeOf(float32(0)):                singleconfig.CFG_FLOAT32,
+	reflect.TypeOf(float64(0)):                singleconfig.CFG_FLOAT64,
+	reflect.TypeOf([]string{}):                singleconfig.CFG_STRINGLIST,
+	reflect.TypeOf([]int{}):                   singleconfig.CFG_INTLIST,
+	reflect.TypeOf(time.Duration(0)):          singleconfig.CFG_DURATION,
+	reflect.TypeOf(time.Time{}):               singleconfig.CFG_TIME,
+	reflect.TypeOf([]time.Duration{}):         singleconfig.CFG_DURATIONLIST,
+	reflect.TypeOf(singleconfig.ByteSize(0)):  singleconfig.CFG_BYTESIZE,
+	reflect.TypeOf(net.IP{}):                  singleconfig.CFG_IP,
+	reflect.TypeOf(&net.IPNet{}):              singleconfig.CFG_CIDR,
+	reflect.TypeOf(singleconfig.HostPort{}):   singleconfig.CFG_HOSTPORT,
+	reflect.TypeOf(&url.URL{}):                singleconfig.CFG_URL,
+	reflect.TypeOf([]net.IP{}):                singleconfig.CFG_IPLIST,
+	reflect.TypeOf([]*net.IPNet{}):            singleconfig.CFG_CIDRLIST,
+	reflect.TypeOf([]singleconfig.HostPort{}): singleconfig.CFG_HOSTPORTLIST,
+	reflect.TypeOf([]int64{}):                 singleconfig.CFG_INT64LIST,
+	reflect.TypeOf([]uint64{}):                singleconfig.CFG_UINT64LIST,
+	reflect.TypeOf([]float64{}):               singleconfig.CFG_FLOAT64LIST,
+	reflect.TypeOf([]bool{}):                  singleconfig.CFG_BOOLLIST,
+	reflect.TypeOf([][]string{}):              singleconfig.CFG_STRINGLISTLIST,
+	reflect.TypeOf([][]int{}):                 singleconfig.CFG_INTLISTLIST,
+	reflect.TypeOf(map[string]string{}):       singleconfig.CFG_STRINGMAP,
+	reflect.TypeOf(map[string]int{}):          singleconfig.CFG_INTMAP,
+	reflect.TypeOf([]*url.URL{}):              singleconfig.CFG_URLLIST,
+}
+
+// configTypeOf returns the typed section of t, named types are matched by their underlying type
+func configTypeOf(t reflect.Type) (configType singleconfig.ConfigType, ok bool) {
+	if t == nil {
+		return "", false
+	}
+	if configType, ok = goTypes[t]; ok {
+		return configType, ok
+	}
+	for goType, configType := range goTypes {
+		if goType.PkgPath() != "" || goType.Kind() == reflect.Slice && goType.Elem().PkgPath() != "" {
+			// time.Duration and friends are only matched exactly
+			continue
+		}
+		if t.Kind() == goType.Kind() && t.ConvertibleTo(goType) && goType.ConvertibleTo(t) {
+			if t.Kind() != reflect.Slice || t.Elem().Kind() == goType.Elem().Kind() {
+				return configType, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Unmarshal fills the struct pointed to by v with the merged configuration.
+// Fields are bound by the multiconfig tag, etc: `multiconfig:"TEST_INT"`,
+// append ",optional" to allow a missing key. Untagged struct fields are
+// filled recursively, pointers are allocated as needed. Every missing or
+// mistyped key is reported in one UnmarshalError.
+func (m *MultiConfig) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("multiconfig: unmarshal needs a non-nil struct pointer, got %T", v)
+	}
+	var errs UnmarshalError
+	m.unmarshalStruct(m.Snapshot(), rv.Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (m *MultiConfig) unmarshalStruct(snapshot Snapshot, rv reflect.Value, path string, errs *UnmarshalError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+		tag := field.Tag.Get("multiconfig")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			if target := structTarget(fv); target.IsValid() {
+				m.unmarshalStruct(snapshot, target, fieldPath, errs)
+			}
+			continue
+		}
+		key, optional := parseTag(tag)
+		if err := setField(snapshot, fv, key); err != nil {
+			if optional && errors.Is(err, ErrKeyNotFound) {
+				continue
+			}
+			*errs = append(*errs, &FieldError{Field: fieldPath, Key: key, Err: err})
+		}
+	}
+}
+
+// structTarget returns the struct an untagged field should be filled through
+func structTarget(fv reflect.Value) reflect.Value {
+	switch {
+	case fv.Kind() == reflect.Struct:
+		return fv
+	case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return fv.Elem()
+	}
+	return reflect.Value{}
+}
+
+func parseTag(tag string) (key string, optional bool) {
+	parts := strings.Split(tag, ",")
+	for _, option := range parts[1:] {
+		if strings.TrimSpace(option) == "optional" {
+			optional = true
+		}
+	}
+	return strings.TrimSpace(parts[0]), optional
+}
+
+// setField fills fv with the value of key, with coercion on a key defined in
+// another section is converted if that is lossless
+func setField(snapshot Snapshot, fv reflect.Value, key string) error {
+	target := fv.Type()
+	configType, ok := goTypes[target]
+	if !ok {
+		if target.Kind() == reflect.Ptr {
+			target = target.Elem()
+		}
+		configType, ok = configTypeOf(target)
+	}
+	if !ok {
+		return fmt.Errorf("unsupported type %v", fv.Type())
+	}
+	value, ok := snapshot.values[configType][key]
+	if !ok {
+		found := keyTypes(snapshot.values, key)
+		if len(found) == 0 {
+			return ErrKeyNotFound
+		}
+		if !snapshot.coerce {
+			return &TypeError{Key: key, Want: configType, Found: found}
+		}
+		var err error
+		if value, err = coerceKey(snapshot.values, key, found, configType); err != nil {
+			return err
+		}
+	}
+	// never hand out memory shared with the merged configuration
+	converted := reflect.ValueOf(clone(value)).Convert(target)
+	if fv.Type() != target {
+		ptr := reflect.New(target)
+		ptr.Elem().Set(converted)
+		converted = ptr
+	}
+	fv.Set(converted)
+	return nil
+}
+
+// keyTypes returns the sections key is defined in
+func keyTypes(values map[singleconfig.ConfigType]map[string]interface{}, key string) (found []singleconfig.ConfigType) {
+	for configType, section := range values {
+		if _, ok := section[key]; ok {
+			found = append(found, configType)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	return found
+}
@@ -0,0 +1,137 @@
+package multiconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// loadConfig writes data to a file and loads it
+func loadConfig(t *testing.T, data string) *MultiConfig {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+const unmarshalConfig = `[sectionString]
+NAME = app
+HOST = localhost
+
+[sectionInt]
+PORT = 8080
+WORKERS = 4
+
+[sectionStringList]
+TAGS = [a,b]
+
+[sectionDuration]
+TIMEOUT = 1m30s
+`
+
+type port int
+
+type tags []string
+
+type server struct {
+	Host    string        `multiconfig:"HOST"`
+	Port    port          `multiconfig:"PORT"`
+	Timeout time.Duration `multiconfig:"TIMEOUT"`
+}
+
+type limits struct {
+	Workers *int `multiconfig:"WORKERS"`
+	Retries int  `multiconfig:"RETRIES,optional"`
+}
+
+type appConfig struct {
+	Name    string `multiconfig:"NAME"`
+	Tags    tags   `multiconfig:"TAGS"`
+	Server  server
+	Limits  *limits
+	Skipped string `multiconfig:"-"`
+	hidden  string `multiconfig:"NAME"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	m := loadConfig(t, unmarshalConfig)
+	config := appConfig{Skipped: "kept"}
+	if err := m.Unmarshal(&config); err != nil {
+		t.Fatal(err)
+	}
+	workers := 4
+	want := appConfig{
+		Name:    "app",
+		Tags:    tags{"a", "b"},
+		Server:  server{Host: "localhost", Port: 8080, Timeout: 90 * time.Second},
+		Limits:  &limits{Workers: &workers},
+		Skipped: "kept",
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Unmarshal filled %+v, want %+v", config, want)
+	}
+
+	// the filled list is a copy
+	config.Tags[0] = "changed"
+	if got := MustGet[[]string](m, "TAGS"); got[0] != "a" {
+		t.Errorf("changing the filled list changed the configuration to %q", got)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	m := loadConfig(t, unmarshalConfig)
+	var config struct {
+		Missing  string `multiconfig:"MISSING"`
+		Mistyped bool   `multiconfig:"PORT"`
+		Nested   struct {
+			Port uint `multiconfig:"HOST"`
+		}
+		Unsupported complex64 `multiconfig:"NAME"`
+		Optional    int       `multiconfig:"ABSENT,optional"`
+	}
+	err := m.Unmarshal(&config)
+	var unmarshalErr UnmarshalError
+	if !errors.As(err, &unmarshalErr) {
+		t.Fatalf("Unmarshal returned %v, want an UnmarshalError", err)
+	}
+	if len(unmarshalErr) != 4 {
+		t.Fatalf("%d field errors, want 4: %v", len(unmarshalErr), err)
+	}
+
+	if e := unmarshalErr[0]; e.Field != "Missing" || e.Key != "MISSING" || !errors.Is(e, ErrKeyNotFound) {
+		t.Errorf("missing key: %v", e)
+	}
+	var typeErr *TypeError
+	if e := unmarshalErr[1]; e.Field != "Mistyped" || !errors.As(e, &typeErr) ||
+		typeErr.Want != singleconfig.CFG_BOOL || !reflect.DeepEqual(typeErr.Found, []singleconfig.ConfigType{singleconfig.CFG_INT}) {
+		t.Errorf("mistyped key: %v", e)
+	}
+	if e := unmarshalErr[2]; e.Field != "Nested.Port" || e.Key != "HOST" || !errors.As(e, &typeErr) || typeErr.Want != singleconfig.CFG_UINT {
+		t.Errorf("mistyped nested key: %v", e)
+	}
+	if e := unmarshalErr[3]; e.Field != "Unsupported" || errors.Is(e, ErrKeyNotFound) || errors.As(e, &typeErr) {
+		t.Errorf("unsupported type: %v", e)
+	}
+}
+
+func TestUnmarshalTarget(t *testing.T) {
+	m := loadConfig(t, unmarshalConfig)
+	var config appConfig
+	var nilConfig *appConfig
+	for _, v := range []interface{}{config, nilConfig, new(int), nil} {
+		if err := m.Unmarshal(v); err == nil {
+			t.Errorf("Unmarshal(%T) is accepted", v)
+		}
+	}
+}
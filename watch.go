@@ -0,0 +1,324 @@
+package multiconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/UangDesign/multiconfig/singleconfig"
+)
+
+// ErrPendingChanges is reported through OnError when the watcher finds a
+// file changed on disk that still has values set with SetValue but not
+// flushed, the file is not reloaded so they are not lost
+var ErrPendingChanges = errors.New("file has values not flushed yet")
+
+// fileStamp identifies one version of a configuration file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	hash    []byte
+}
+
+// stampFile reads the current stamp of filePath, the hash is only computed
+// when the modification time or size differ from prev
+func stampFile(filePath string, prev fileStamp) (stamp fileStamp, err error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return stamp, err
+	}
+	stamp = fileStamp{modTime: info.ModTime(), size: info.Size(), hash: prev.hash}
+	if prev.hash != nil && stamp.modTime.Equal(prev.modTime) && stamp.size == prev.size {
+		return stamp, nil
+	}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return stamp, err
+	}
+	sum := sha256.Sum256(data)
+	stamp.hash = sum[:]
+	return stamp, nil
+}
+
+type watcher struct {
+	interval time.Duration
+	stamps   map[string]fileStamp // guarded by MultiConfig.lock
+	// state of every file whose failure was reported, the same failure is
+	// not reported again until the state changes, guarded by MultiConfig.lock
+	reported map[string]string
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// report records state as the failure of filePath and returns whether it
+// differs from the one reported last
+func (w *watcher) report(filePath, state string) bool {
+	if prev, ok := w.reported[filePath]; ok && prev == state {
+		return false
+	}
+	w.reported[filePath] = state
+	return true
+}
+
+// OnChange registers fn to be called with the previous and the current
+// snapshot whenever a reload or SetValue changes the merged configuration.
+// Changes are passed on one at a time in the order they were made, so the
+// new snapshot of the last call is the current one. fn runs after the lock
+// is released on a goroutine that made a change, not necessarily this one
+func (m *MultiConfig) OnChange(fn func(old, new Snapshot)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// OnError registers fn to be called when the watcher fails to reload a file,
+// the previous configuration stays in effect. With CONFLICT_WARN fn is also
+// called with a *ConflictError for every conflicting key after a load
+func (m *MultiConfig) OnError(fn func(err error)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.onError = append(m.onError, fn)
+}
+
+// snapshotChange is a change of the merged configuration not yet passed to
+// the OnChange listeners
+type snapshotChange struct {
+	old, new Snapshot
+}
+
+// deliver passes the queued changes to the OnChange listeners in the order
+// they were made, the caller does not hold m.lock. Only one goroutine
+// delivers at a time, changes queued meanwhile, also by a listener, are
+// delivered by it before it returns
+func (m *MultiConfig) deliver() {
+	m.lock.Lock()
+	if m.delivering {
+		m.lock.Unlock()
+		return
+	}
+	m.delivering = true
+	done := false
+	defer func() {
+		if !done {
+			// a listener panicked, the next change is delivered by its writer
+			m.lock.Lock()
+			m.delivering = false
+			m.lock.Unlock()
+		}
+	}()
+	for len(m.changes) > 0 {
+		change := m.changes[0]
+		m.changes = m.changes[1:]
+		listeners := append([]func(old, new Snapshot){}, m.onChange...)
+		m.lock.Unlock()
+		for _, fn := range listeners {
+			fn(change.old, change.new)
+		}
+		m.lock.Lock()
+	}
+	m.changes, m.delivering, done = nil, false, true
+	m.lock.Unlock()
+}
+
+func (m *MultiConfig) notifyError(err error) {
+	m.lock.Lock()
+	listeners := append([]func(err error){}, m.onError...)
+	m.lock.Unlock()
+	for _, fn := range listeners {
+		fn(err)
+	}
+}
+
+// Reload reads every file again and rebuilds the merged configuration,
+// keys removed from a file disappear. Values set with SetValue but not yet
+// flushed are lost. On error, including a strict mode value error, the
+// previous configuration stays in effect.
+func (m *MultiConfig) Reload() error {
+	m.lock.Lock()
+	paths := make([]string, 0, len(m.multiConfig))
+	for _, singleConfig := range m.multiConfig {
+		paths = append(paths, singleConfig.GetConfPath())
+	}
+	conflicts, err := m.reloadFiles(paths)
+	m.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	m.warnConflicts(conflicts)
+	m.deliver()
+	return nil
+}
+
+// reloadFiles replaces the layers loaded from paths, the caller holds m.lock.
+// conflicts holds the conflicting keys to warn about with CONFLICT_WARN
+func (m *MultiConfig) reloadFiles(paths []string) (conflicts ConflictErrors, err error) {
+	layers := append([]*singleconfig.SingleConfig{}, m.multiConfig...)
+	for _, filePath := range paths {
+		reloaded, err := singleconfig.LoadSingleConfig(filePath)
+		if err != nil {
+			return nil, err
+		}
+		reloaded.SetBackups(m.backups)
+		for i := range layers {
+			if layers[i].GetConfPath() == filePath {
+				layers[i] = reloaded
+			}
+		}
+	}
+	if m.strict {
+		if err = m.validateLayers(layers); err != nil {
+			return nil, err
+		}
+	} else if m.conflictMode == CONFLICT_ERROR {
+		if conflicts := findConflicts(layers); len(conflicts) > 0 {
+			return nil, conflicts
+		}
+	}
+	if m.conflictMode == CONFLICT_WARN {
+		conflicts = findConflicts(layers)
+	}
+	m.multiConfig = layers
+	m.rebuild()
+	return conflicts, nil
+}
+
+// Watch polls every file each interval and reloads the files whose
+// modification time, size and content hash changed. A file with values not
+// flushed yet is not reloaded, ErrPendingChanges is reported once instead
+// and Flush refuses to overwrite the file with singleconfig.ErrFileChanged.
+// Reload picks the change up and drops the values.
+// Callers are informed through OnChange and OnError, a file that can not be
+// read is reported once until its error changes. Calling Watch again
+// replaces the interval, which must be positive.
+func (m *MultiConfig) Watch(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("multiconfig: watch interval %v is not positive", interval)
+	}
+	m.StopWatch()
+	w := &watcher{
+		interval: interval,
+		stamps:   make(map[string]fileStamp),
+		reported: make(map[string]string),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	m.lock.Lock()
+	for _, singleConfig := range m.multiConfig {
+		// a file that can not be stamped now is picked up by the first poll
+		stamp, _ := stampFile(singleConfig.GetConfPath(), fileStamp{})
+		w.stamps[singleConfig.GetConfPath()] = stamp
+	}
+	m.watch = w
+	m.lock.Unlock()
+	go m.poll(w)
+	return nil
+}
+
+// StopWatch stops the watcher started by Watch and waits for it to exit
+func (m *MultiConfig) StopWatch() {
+	m.lock.Lock()
+	w := m.watch
+	m.watch = nil
+	m.lock.Unlock()
+	if w != nil {
+		close(w.stop)
+		<-w.done
+	}
+}
+
+func (m *MultiConfig) poll(w *watcher) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			m.checkFiles(w)
+		}
+	}
+}
+
+func (m *MultiConfig) checkFiles(w *watcher) {
+	m.lock.Lock()
+	if m.watch != w {
+		// stopped meanwhile
+		m.lock.Unlock()
+		return
+	}
+	errs := make([]error, 0)
+	changed := make([]string, 0)
+	for filePath, prev := range w.stamps {
+		stamp, err := stampFile(filePath, prev)
+		if err != nil {
+			if w.report(filePath, err.Error()) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if bytes.Equal(stamp.hash, prev.hash) {
+			w.stamps[filePath] = stamp
+			delete(w.reported, filePath)
+			continue
+		}
+		if m.dirty(filePath) {
+			// the stamp stays, so the change is seen again once the values
+			// are flushed or dropped, a flush refuses to overwrite it
+			if w.report(filePath, string(stamp.hash)) {
+				errs = append(errs, fmt.Errorf("multiconfig: reload %s: %w", filePath, ErrPendingChanges))
+			}
+			continue
+		}
+		// a file that fails to reload is retried once it changes again
+		w.stamps[filePath] = stamp
+		delete(w.reported, filePath)
+		changed = append(changed, filePath)
+	}
+	var conflicts ConflictErrors
+	var err error
+	if len(changed) > 0 {
+		conflicts, err = m.reloadFiles(changed)
+	}
+	m.lock.Unlock()
+	for _, err := range errs {
+		m.notifyError(err)
+	}
+	if len(changed) == 0 {
+		return
+	}
+	if err != nil {
+		m.notifyError(err)
+		return
+	}
+	m.warnConflicts(conflicts)
+	m.deliver()
+}
+
+// dirty reports whether the layer loaded from filePath has values not
+// flushed yet, the caller holds m.lock
+func (m *MultiConfig) dirty(filePath string) bool {
+	for _, singleConfig := range m.multiConfig {
+		if singleConfig.GetConfPath() == filePath && singleConfig.Dirty() {
+			return true
+		}
+	}
+	return false
+}
+
+// restamp records the current stamp of the files the library just wrote, so
+// the watcher does not reload them, the caller holds m.lock
+func (m *MultiConfig) restamp(paths []string) {
+	if m.watch == nil {
+		return
+	}
+	for _, filePath := range paths {
+		if stamp, err := stampFile(filePath, fileStamp{}); err == nil {
+			m.watch.stamps[filePath] = stamp
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package multiconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errorLog collects the errors reported through OnError
+type errorLog struct {
+	lock sync.Mutex
+	errs []error
+}
+
+func (l *errorLog) add(err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.errs = append(l.errs, err)
+}
+
+// count returns how many reported errors match target
+func (l *errorLog) count(target error) (n int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, err := range l.errs {
+		if errors.Is(err, target) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWatchPendingChanges(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	if err := os.WriteFile(filePath, []byte("[sectionInt]\nN = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := &errorLog{}
+	m.OnError(errs.add)
+	if err := m.Watch(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopWatch()
+
+	if err := m.SetValue("N", 2, filePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte("[sectionInt]\nN = 1\nEXTERNAL = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := errs.count(ErrPendingChanges); n != 1 {
+		t.Errorf("ErrPendingChanges reported %d times, want once", n)
+	}
+	if got := MustGet[int](m, "N"); got != 2 {
+		t.Errorf("the watcher dropped the value not flushed, N = %d", got)
+	}
+
+	// once the values are dropped the change is picked up
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := Get[int](m, "EXTERNAL"); !ok {
+		t.Error("EXTERNAL is missing after the reload")
+	}
+	if n := errs.count(ErrPendingChanges); n != 1 {
+		t.Errorf("ErrPendingChanges reported %d times after the reload", n)
+	}
+}
+
+func TestWatchInterval(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	if err := os.WriteFile(filePath, []byte("[sectionInt]\nN = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if err := m.Watch(interval); err == nil {
+			m.StopWatch()
+			t.Errorf("Watch(%v) is accepted", interval)
+		}
+	}
+}
+
+func TestWatchMissingFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "config.conf")
+	if err := os.WriteFile(filePath, []byte("[sectionInt]\nN = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMultiConfig(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := &errorLog{}
+	m.OnError(errs.add)
+	if err := m.Watch(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopWatch()
+
+	if err := os.Remove(filePath); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := errs.count(os.ErrNotExist); n != 1 {
+		t.Errorf("the missing file was reported %d times, want once", n)
+	}
+	if got := MustGet[int](m, "N"); got != 1 {
+		t.Errorf("N = %d while the file is missing", got)
+	}
+
+	// a file that comes back is reloaded, a second removal is reported again
+	if err := os.WriteFile(filePath, []byte("[sectionInt]\nN = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := MustGet[int](m, "N"); got != 2 {
+		t.Errorf("N = %d after the file came back", got)
+	}
+	if err := os.Remove(filePath); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := errs.count(os.ErrNotExist); n != 2 {
+		t.Errorf("the missing file was reported %d times, want twice", n)
+	}
+}